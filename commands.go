@@ -0,0 +1,143 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/auth"
+	"github.com/jtrotsky/go-poynt/poyntcloud/catalog"
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/hooks"
+	"github.com/jtrotsky/go-poynt/poyntcloud/reports"
+)
+
+// commands are the one-off tasks that can be run instead of the webserver, e.g.
+// go-poynt sync-catalog -feed products.csv -catalog <id>
+var commands = map[string]func(c *client.Client, args []string) error{
+	"sync-catalog":    syncCatalog,
+	"reconcile-hooks": reconcileHooks,
+	"settlements":     exportSettlements,
+}
+
+// runCommand authenticates with POYNT and runs the named command.
+func runCommand(name string, args []string) {
+	command, ok := commands[name]
+	if !ok {
+		fmt.Println("Unknown command:", name)
+		fmt.Println("Available commands:")
+		for name := range commands {
+			fmt.Println("  ", name)
+		}
+		os.Exit(2)
+	}
+
+	config, err := config.GetConfig()
+	if err != nil {
+		fmt.Println("Error getting config:", err)
+		os.Exit(1)
+	}
+	creds, err := auth.GetAuth(config)
+	if err != nil {
+		fmt.Println("Error getting auth:", err)
+		os.Exit(1)
+	}
+
+	if err := command(client.NewClient(config, creds), args); err != nil {
+		fmt.Printf("Error running %s: %s\n", name, err)
+		os.Exit(1)
+	}
+}
+
+// syncCatalog reconciles a product feed exported from the POS into a catalog.
+func syncCatalog(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("sync-catalog", flag.ExitOnError)
+	feedFile := flags.String("feed", "", "product feed to sync, .json or .csv")
+	catalogID := flags.String("catalog", "", "ID of the POYNT catalog to sync into")
+	currency := flags.String("currency", c.Config.Currency, "currency of feed prices that do not give one")
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	removeMissing := flags.Bool("remove-missing", false, "remove products not in the feed from the catalog")
+	flags.Parse(args)
+
+	if *feedFile == "" || *catalogID == "" {
+		flags.Usage()
+		return fmt.Errorf("-feed and -catalog are required")
+	}
+
+	feed, err := catalog.LoadFeed(*feedFile, *currency)
+	if err != nil {
+		return err
+	}
+	options := catalog.SyncOptions{DryRun: *dryRun, RemoveMissing: *removeMissing}
+	plan, err := catalog.Sync(context.Background(), c, feed, *catalogID, options)
+	if plan != nil {
+		plan.Print(os.Stdout)
+	}
+	return err
+}
+
+// reconcileHooks makes the registered webhook subscriptions match the
+// "webhooks" list in config.
+func reconcileHooks(c *client.Client, args []string) error {
+	flags := flag.NewFlagSet("reconcile-hooks", flag.ExitOnError)
+	dryRun := flags.Bool("dry-run", false, "print the changes without making them")
+	flags.Parse(args)
+
+	changes, err := hooks.Reconcile(context.Background(), c, c.Config.Webhooks, *dryRun)
+	for _, change := range changes {
+		fmt.Println(change)
+	}
+	if len(changes) == 0 && err == nil {
+		fmt.Println("Webhooks already match config")
+	}
+	return err
+}
+
+// exportSettlements writes a store's settlement batches for a date range as CSV
+// or JSON.
+func exportSettlements(c *client.Client, args []string) error {
+	today := time.Now().Format("2006-01-02")
+	flags := flag.NewFlagSet("settlements", flag.ExitOnError)
+	storeID := flags.String("store", c.Config.StoreID, "ID of the store to report on")
+	from := flags.String("from", today, "first day to report on, YYYY-MM-DD")
+	to := flags.String("to", today, "last day to report on, YYYY-MM-DD")
+	format := flags.String("format", "csv", "output format, csv or json")
+	out := flags.String("out", "", "file to write to, default stdout")
+	flags.Parse(args)
+
+	fromDate, err := time.ParseInLocation("2006-01-02", *from, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -from date: %s", err)
+	}
+	toDate, err := time.ParseInLocation("2006-01-02", *to, time.Local)
+	if err != nil {
+		return fmt.Errorf("invalid -to date: %s", err)
+	}
+
+	batches, err := reports.ListBatches(context.Background(), c, *storeID, fromDate, toDate.AddDate(0, 0, 1))
+	if err != nil {
+		return err
+	}
+
+	var w io.Writer = os.Stdout
+	if *out != "" {
+		file, err := os.Create(*out)
+		if err != nil {
+			return fmt.Errorf("error creating output file: %s", err)
+		}
+		defer file.Close()
+		w = file
+	}
+
+	switch *format {
+	case "csv":
+		return reports.WriteCSV(w, batches)
+	case "json":
+		return reports.WriteJSON(w, batches)
+	}
+	return fmt.Errorf("unknown format %q", *format)
+}
@@ -35,6 +35,12 @@ func init() {
 }
 
 func main() {
+	// Run a one-off command if one was given.
+	if len(os.Args) > 1 {
+		runCommand(os.Args[1], os.Args[2:])
+		return
+	}
+
 	// Run the webserver on port 8000.
 	server.Run()
 }
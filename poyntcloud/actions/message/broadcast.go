@@ -0,0 +1,107 @@
+package poyntcloud
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/devices"
+)
+
+// DefaultBroadcastConcurrency is how many messages a broadcast sends at once
+// if no limit is given.
+const DefaultBroadcastConcurrency = 4
+
+// BroadcastResult is the outcome of sending a broadcast to one terminal.
+type BroadcastResult struct {
+	DeviceID     string `json:"deviceId"`
+	SerialNumber string `json:"serialNumber,omitempty"`
+	Name         string `json:"name,omitempty"`
+	MessageID    string `json:"messageId,omitempty"`
+	Error        string `json:"error,omitempty"`
+}
+
+// BroadcastError is returned when some terminals did not get a broadcast.
+type BroadcastError struct {
+	Failed int
+	Total  int
+}
+
+func (e *BroadcastError) Error() string {
+	return fmt.Sprintf("broadcast failed for %d of %d terminals", e.Failed, e.Total)
+}
+
+// Broadcast sends a copy of the message to each of the devices, at most
+// concurrency at a time. It returns a result for every device, in the order
+// given, and a *BroadcastError if any of them failed.
+func Broadcast(ctx context.Context, c *client.Client, m *Message, targets []devices.Device, concurrency int) ([]BroadcastResult, error) {
+	if concurrency < 1 {
+		concurrency = DefaultBroadcastConcurrency
+	}
+
+	results := make([]BroadcastResult, len(targets))
+	semaphore := make(chan struct{}, concurrency)
+	wg := sync.WaitGroup{}
+	for i, device := range targets {
+		results[i] = BroadcastResult{
+			DeviceID:     device.DeviceID,
+			SerialNumber: device.SerialNumber,
+			Name:         device.Name,
+		}
+
+		// Each terminal gets its own copy addressed to it alone.
+		deviceMessage := *m
+		deviceMessage.StoreID = device.StoreID
+		deviceMessage.ToDevice(device.DeviceID)
+		deviceMessage.SerialNumber = ""
+
+		wg.Add(1)
+		semaphore <- struct{}{}
+		go func(result *BroadcastResult, deviceMessage *Message) {
+			defer wg.Done()
+			defer func() { <-semaphore }()
+			messageID, err := Send(ctx, c, deviceMessage)
+			if err != nil {
+				result.Error = err.Error()
+				return
+			}
+			result.MessageID = messageID
+		}(&results[i], &deviceMessage)
+	}
+	wg.Wait()
+
+	failed := 0
+	for _, result := range results {
+		if result.Error != "" {
+			failed++
+		}
+	}
+	if failed > 0 {
+		return results, &BroadcastError{Failed: failed, Total: len(results)}
+	}
+	return results, nil
+}
+
+// BroadcastToStore sends the message to every activated terminal in a store.
+func BroadcastToStore(ctx context.Context, c *client.Client, m *Message, storeID string, concurrency int) ([]BroadcastResult, error) {
+	storeDevices, err := devices.List(ctx, c, storeID)
+	if err != nil {
+		return nil, err
+	}
+
+	var targets []devices.Device
+	for _, device := range storeDevices {
+		if device.Status == devices.StatusActivated {
+			if device.StoreID == "" {
+				device.StoreID = storeID
+			}
+			targets = append(targets, device)
+		}
+	}
+	if len(targets) == 0 {
+		return nil, errors.New("no activated terminals in store")
+	}
+	return Broadcast(ctx, c, m, targets, concurrency)
+}
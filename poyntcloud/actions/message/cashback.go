@@ -0,0 +1,62 @@
+package poyntcloud
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// WithCashBack adds cash out to the payment within the store's limits. The
+// amount is rounded down to the store's rounding, and is only sent on debit:
+// asking for cash back makes the payment debit unless the payment policy
+// requires credit.
+func (p *Payment) WithCashBack(amount money.Money, limits config.CashBack) error {
+	if amount.IsZero() {
+		p.CashbackAmount = 0
+		return nil
+	}
+	if !limits.Enabled {
+		return errors.New("cash back is not offered by this store")
+	}
+	if amount.Currency != p.CurrencyCode {
+		return money.ErrCurrencyMismatch
+	}
+	if !amount.IsPositive() {
+		return errors.New("cash back amount must not be negative")
+	}
+	if p.CardType() == CardCredit {
+		return errors.New("cash back is only available on debit")
+	}
+
+	if limits.Rounding != "" {
+		rounding, err := money.Parse(limits.Rounding, p.CurrencyCode)
+		if err != nil || !rounding.IsPositive() {
+			return fmt.Errorf("invalid cash back rounding %q", limits.Rounding)
+		}
+		amount.Amount -= amount.Amount % rounding.Amount
+		if amount.IsZero() {
+			return fmt.Errorf("cash back must be at least %s", rounding)
+		}
+	}
+	if limits.MaxAmount != "" {
+		max, err := money.Parse(limits.MaxAmount, p.CurrencyCode)
+		if err != nil {
+			return fmt.Errorf("invalid cash back limit %q", limits.MaxAmount)
+		}
+		if cmp, _ := amount.Cmp(max); cmp > 0 {
+			return fmt.Errorf("cash back of %s is more than the %s limit", amount, max)
+		}
+	}
+
+	p.IsDebit = true
+	p.DisableDebitCards = false
+	p.CashbackAmount = amount.Amount
+	return nil
+}
+
+// CashBack returns the cash back amount of the payment.
+func (p *Payment) CashBack() money.Money {
+	return money.New(p.CashbackAmount, p.CurrencyCode)
+}
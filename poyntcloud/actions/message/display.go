@@ -0,0 +1,71 @@
+package poyntcloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Screens are only worth showing while the customer is at the counter.
+const displayTTL = 10 * time.Second
+
+// Screen is a custom text and/or image screen shown on the terminal's
+// customer facing display.
+type Screen struct {
+	Action   string `json:"action"`
+	Title    string `json:"title,omitempty"`
+	Text     string `json:"text,omitempty"`
+	ImageURL string `json:"imageUrl,omitempty"`
+}
+
+// NewScreen creates a screen with the given title, text and image. Any of them
+// may be empty.
+func NewScreen(title, text, imageURL string) *Screen {
+	return &Screen{Action: "display", Title: title, Text: text, ImageURL: imageURL}
+}
+
+// Cart is the running total shown on the terminal while the cashier scans
+// items. Amounts are in minor units of the currency.
+type Cart struct {
+	Action       string     `json:"action"`
+	Items        []CartItem `json:"items,omitempty"`
+	TotalAmount  int64      `json:"totalAmount"`
+	CurrencyCode string     `json:"currency"`
+}
+
+// CartItem is a line of the cart.
+type CartItem struct {
+	Name     string `json:"name"`
+	Quantity int    `json:"quantity"`
+	Amount   int64  `json:"amount"`
+}
+
+// NewCart creates a cart display for the given total.
+func NewCart(total money.Money, items []CartItem) *Cart {
+	return &Cart{Action: "cart", Items: items, TotalAmount: total.Amount, CurrencyCode: total.Currency}
+}
+
+// ShowScreen shows a screen on the configured terminal, replacing any screen or
+// cart still waiting to be shown.
+func ShowScreen(ctx context.Context, c *client.Client, screen *Screen) (string, error) {
+	return sendDisplay(ctx, c, screen)
+}
+
+// ShowCart shows the running cart total on the configured terminal, replacing
+// any screen or cart still waiting to be shown.
+func ShowCart(ctx context.Context, c *client.Client, cart *Cart) (string, error) {
+	return sendDisplay(ctx, c, cart)
+}
+
+// sendDisplay sends a display payload. Only the latest display matters, so they
+// share a collapse key.
+func sendDisplay(ctx context.Context, c *client.Client, payload interface{}) (string, error) {
+	message, err := NewMessage(c.Config, payload)
+	if err != nil {
+		return "", err
+	}
+	message.WithTTL(displayTTL).WithCollapseKey("display")
+	return Send(ctx, c, message)
+}
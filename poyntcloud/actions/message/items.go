@@ -0,0 +1,81 @@
+package poyntcloud
+
+import (
+	"errors"
+	"fmt"
+	"math"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// SurchargeSKU identifies the line added for a card surcharge.
+const SurchargeSKU = "CARD-SURCHARGE"
+
+// LineItem is an item bought, shown on the terminal and on POYNT receipts.
+// Amounts are in minor units of the payment currency.
+type LineItem struct {
+	Name      string  `json:"name"`
+	SKU       string  `json:"sku,omitempty"`
+	Quantity  float64 `json:"quantity"`
+	UnitPrice int64   `json:"unitPrice"`
+	Tax       int64   `json:"tax,omitempty"`      // For the whole line
+	Discount  int64   `json:"discount,omitempty"` // For the whole line
+}
+
+// Total returns what the line costs, rounded to the minor unit: the quantity
+// at the unit price, less the discount, plus tax.
+func (item LineItem) Total() int64 {
+	return int64(math.Round(item.Quantity*float64(item.UnitPrice))) - item.Discount + item.Tax
+}
+
+// WithItems adds the items bought to the payment. They must add up to the
+// purchase amount, not counting a card surcharge, which is added as a line of
+// its own.
+func (p *Payment) WithItems(items []LineItem) error {
+	var total int64
+	for _, item := range items {
+		switch {
+		case item.Name == "":
+			return errors.New("line item name is required")
+		case item.Quantity <= 0:
+			return fmt.Errorf("line item %q quantity must be positive", item.Name)
+		case item.UnitPrice < 0 || item.Tax < 0 || item.Discount < 0:
+			return fmt.Errorf("line item %q amounts must not be negative", item.Name)
+		case item.SKU == SurchargeSKU:
+			return fmt.Errorf("line item SKU %s is reserved", SurchargeSKU)
+		}
+		total += item.Total()
+	}
+
+	purchase := p.PurchaseAmount - p.SurchargeAmount
+	if total != purchase {
+		return fmt.Errorf("line items total %s does not match purchase amount %s",
+			money.New(total, p.CurrencyCode), money.New(purchase, p.CurrencyCode))
+	}
+	p.Items = items
+	p.setSurchargeLine()
+	return nil
+}
+
+// setSurchargeLine keeps the surcharge line in step with the surcharge, when
+// the payment has items.
+func (p *Payment) setSurchargeLine() {
+	if len(p.Items) == 0 {
+		return
+	}
+	items := p.Items[:0]
+	for _, item := range p.Items {
+		if item.SKU != SurchargeSKU {
+			items = append(items, item)
+		}
+	}
+	if p.SurchargeAmount > 0 {
+		items = append(items, LineItem{
+			Name:      "Card surcharge",
+			SKU:       SurchargeSKU,
+			Quantity:  1,
+			UnitPrice: p.SurchargeAmount,
+		})
+	}
+	p.Items = items
+}
@@ -1,26 +1,55 @@
 package poyntcloud
 
 import (
-	"bytes"
+	"context"
 	"encoding/json"
-	"errors"
 	"fmt"
-	"io/ioutil"
+	"log"
 	"net/http"
 	"time"
 
-	"github.com/jtrotsky/go-poynt/poyntcloud"
 	"github.com/jtrotsky/go-poynt/poyntcloud/auth"
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
 	"github.com/jtrotsky/go-poynt/poyntcloud/config"
 )
 
-// Message contains message body content of a CloudMessage post
+// DefaultTTL is how long a message is held for a terminal that is not
+// connected, if no TTL is set.
+const DefaultTTL = 60 * time.Second
+
+// Message is a CloudMessage passed by the POYNT cloud to an application running
+// on a terminal. It can be narrowed from the whole business down to a store, a
+// single device and a receiving class within the app.
 type Message struct {
-	BusinessID string `json:"businessId,omitempty"`
-	// StoreID           string    `json:"storeId,omitempty"`
-	MessageExpiryTime int64 `json:"ttl,omitempty"` // This is a time until the message expires.
-	// Recipient         Recipient `json:"recipient,omitempty"`
-	Data string `json:"data"`
+	BusinessID   string     `json:"businessId,omitempty"`
+	StoreID      string     `json:"storeId,omitempty"`
+	DeviceID     string     `json:"deviceId,omitempty"`
+	SerialNumber string     `json:"serialNum,omitempty"`
+	Recipient    *Recipient `json:"recipient,omitempty"`
+	// TTL is how long POYNT holds the message for a terminal that is offline
+	// before dropping it. It has no effect once the message is delivered, which
+	// is why a short TTL appears not to work against an online terminal. It is
+	// sent as whole seconds, rounded up; zero uses DefaultTTL.
+	TTL         time.Duration `json:"-"`
+	CollapseKey string        `json:"collapseKey,omitempty"`
+	Data        string        `json:"data"`
+}
+
+// MarshalJSON sends the TTL as whole seconds, as POYNT expects.
+func (m *Message) MarshalJSON() ([]byte, error) {
+	type message Message
+	return json.Marshal(&struct {
+		*message
+		TTL int64 `json:"ttl"`
+	}{(*message)(m), ttlSeconds(m.TTL)})
+}
+
+// ttlSeconds converts a TTL into whole seconds, rounding up.
+func ttlSeconds(ttl time.Duration) int64 {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	return int64((ttl + time.Second - 1) / time.Second)
 }
 
 // Recipient contains application information that is expected to receive the cloud message
@@ -29,105 +58,84 @@ type Recipient struct {
 	PackageName string `json:"packageName,omitempty"`
 }
 
-// Payment is the payment information required for the payment fragment payload
-type Payment struct {
-	Action         string `json:"action"`
-	IsDebit        bool   `json:"isDebit,omitempty"`
-	PurchaseAmount int64  `json:"purchaseAmount"`
-	TipAmount      int64  `json:"tipAmount"`
-	CurrencyCode   string `json:"currency"`
-	ReferenceID    string `json:"referenceId"`
-	OrderID        string `json:"orderId"`
-	CallBackURL    string `json:"callbackUrl"`
+// sentMessage is the response to a created CloudMessage.
+type sentMessage struct {
+	ID string `json:"id"`
 }
 
-// SendCloudMessage sends a message to the POYNT cloud which passes that message
-// on to an application running on the POYNT device.
-func SendCloudMessage(config *config.Configuration, creds *auth.OAuthCreds,
-	paymentAmount float64, referenceID string) error {
-
-	// Check if auth token is expired.
-	if creds.Expiry.IsZero() && creds.Expiry.Unix() < time.Now().Unix() {
-		fmt.Println("Token Expired")
-		return errors.New("Token Expired.")
+// NewMessage creates a message carrying the given payload, marshalled to JSON,
+// addressed using the business, store, device and recipient in config.
+func NewMessage(config *config.Configuration, payload interface{}) (*Message, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, fmt.Errorf("error marshalling message payload: %s", err)
 	}
 
-	var paymentData = Payment{
-		Action:  "sale",
-		IsDebit: true, // TODO: Should be debit or credit? Or optional?
-		// Convert amounts to int as only Java long accepted. Last two digits are
-		// assumed to be cents, hence * 100.
-		PurchaseAmount: int64(paymentAmount * 100),
-		TipAmount:      0, // We don't tip in New Zealand.
-		// TipAmount:      int64(paymentAmountFloat * 0.20),
-		CurrencyCode: "NZD",       // TODO: Should be USD.
-		ReferenceID:  referenceID, // ReferenceID generated for each transaction.
-		// Need to use saleID.
-		// Will not have this when Weggie starts generating it server-side.
-		// Could use register_id, but that would not be changing per transaction.
-		// Could combine register_id with another changing param.
-		OrderID:     "test-order-123",
-		CallBackURL: "https://736ed89f.ngrok.com/callback",
+	message := &Message{
+		BusinessID: config.BusinessID,
+		StoreID:    config.StoreID,
+		DeviceID:   config.DeviceID,
+		Data:       string(data),
 	}
-	paymentDataJSON, err := json.Marshal(&paymentData)
-	if err != nil {
-		fmt.Println("error marshalling payment data:", err)
+	if config.PackageName != "" || config.ClassName != "" {
+		message.ToRecipient(config.PackageName, config.ClassName)
 	}
-	data := bytes.NewBuffer(paymentDataJSON)
+	return message, nil
+}
 
-	cloudMessage := &Message{
-		BusinessID:        config.BusinessID,
-		MessageExpiryTime: 30, // TODO: Tested this, didn't work. Need to figure out.
-		Data:              data.String(),
-	}
+// ToStore addresses the message to every terminal in a store.
+func (m *Message) ToStore(storeID string) *Message {
+	m.StoreID = storeID
+	m.DeviceID = ""
+	m.SerialNumber = ""
+	return m
+}
 
-	cloudMessageURL := config.PoyntAPIHostURL + "/cloudMessages"
-	messagePayload, err := json.Marshal(&cloudMessage)
-	if err != nil {
-		fmt.Println("Error marshalling message JSON:", err)
-	}
+// ToDevice addresses the message to a single terminal by device ID.
+func (m *Message) ToDevice(deviceID string) *Message {
+	m.DeviceID = deviceID
+	return m
+}
 
-	fmt.Println("-----------------------------------------------------------")
-	fmt.Printf("\nSending CloudMessage to POYNT\n")
-	fmt.Printf("MESSAGE:\n %s", messagePayload)
+// ToSerialNumber addresses the message to a single terminal by serial number.
+func (m *Message) ToSerialNumber(serialNumber string) *Message {
+	m.SerialNumber = serialNumber
+	return m
+}
 
-	req, err := http.NewRequest("POST", cloudMessageURL, bytes.NewBuffer(messagePayload))
-	// TODO: clean up headers
-	req.Header.Set("Authorization", creds.TokenType+" "+creds.AccessToken)
-	req.Header.Set("Content-Type", "application/json")
-	// Create UUID for requestID
-	requestID := poyntcloud.GenerateReferenceID()
-	req.Header.Set("Poynt-Request-Id", requestID)
-	req.Header.Set("User-Agent", "Go-Poynt")
+// ToRecipient sets the app and class on the terminal that receives the message.
+func (m *Message) ToRecipient(packageName, className string) *Message {
+	m.Recipient = &Recipient{ClassName: className, PackageName: packageName}
+	return m
+}
 
-	// NOTE: for debug
-	fmt.Printf("\n\nREQUEST ID: %s", requestID)
+// WithTTL sets how long the message is kept for an offline terminal.
+func (m *Message) WithTTL(ttl time.Duration) *Message {
+	m.TTL = ttl
+	return m
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		fmt.Println("Error performing HTTP request:", err)
-	}
-	defer resp.Body.Close()
+// WithCollapseKey sets a key under which a newer undelivered message replaces
+// an older one.
+func (m *Message) WithCollapseKey(key string) *Message {
+	m.CollapseKey = key
+	return m
+}
 
-	body, err := ioutil.ReadAll(resp.Body)
-	if err != nil {
-		fmt.Println("Error reading response body:", err)
+// Send posts the message to the POYNT cloud and returns the created message ID.
+func Send(ctx context.Context, c *client.Client, m *Message) (string, error) {
+	if c.Config.Debug {
+		log.Printf("Sending cloud message to POYNT: %s", m.Data)
 	}
 
-	fmt.Printf("\n\nRESPONSE:\n%d %s\n", resp.StatusCode, body)
-
-	if resp.StatusCode == 401 {
-		var authResponse = auth.Response{}
-		err := json.Unmarshal(body, authResponse)
-		if err != nil {
-			fmt.Println("Error unmarshalling response payload:", err)
-		}
-		if authResponse.Code == "INVALID_ACCESS_TOKEN" {
-			return errors.New("Invalid access token. Probably expired.")
-		}
-		url := auth.BuildOAuthURL(config)
+	sent := sentMessage{}
+	err := c.Do(ctx, "POST", "/cloudMessages", nil, m, &sent)
+	if apiErr, ok := err.(*client.APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
+		url := auth.BuildOAuthURL(c.Config)
 		fmt.Println("Please visit and authorize application at:", url)
-		return err
 	}
-	return err
+	if err != nil {
+		return "", fmt.Errorf("error sending cloud message: %s", err)
+	}
+	return sent.ID, nil
 }
@@ -0,0 +1,109 @@
+package poyntcloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// callbackURL is where the terminal app posts the result of an action.
+const callbackURL = "https://736ed89f.ngrok.com/callback"
+
+// Payment fragment actions.
+const (
+	// ActionSale authorizes and captures the amount in one go.
+	ActionSale = "sale"
+	// ActionAuthorize only authorizes the amount, which is captured later, e.g.
+	// when a bar tab is closed.
+	ActionAuthorize = "authorize"
+	// ActionRefund pays the amount back to the card the customer presents.
+	ActionRefund = "refund"
+)
+
+// Payment is the payment information required for the payment fragment payload
+type Payment struct {
+	Action         string `json:"action"`
+	IsDebit        bool   `json:"isDebit,omitempty"`
+	PurchaseAmount int64  `json:"purchaseAmount"`
+	TipAmount      int64  `json:"tipAmount"`
+	CashbackAmount int64  `json:"cashbackAmount,omitempty"`
+	CurrencyCode   string `json:"currency"`
+	ReferenceID    string `json:"referenceId"`
+	OrderID        string `json:"orderId"`
+	CallBackURL    string `json:"callbackUrl"`
+	CustomerID     int64  `json:"customerUserId,omitempty"`
+	// TransactionID is the original transaction a refund is for, if known.
+	TransactionID string `json:"transactionId,omitempty"`
+	// DisableTip skips the terminal's tip screen.
+	DisableTip bool `json:"disableTip,omitempty"`
+	// TipPercentages are the presets offered on the tip screen, e.g. 15, 18, 20.
+	TipPercentages []int `json:"tipPercentages,omitempty"`
+	// Card and tender restrictions, see WithPolicy.
+	DisableDebitCards bool `json:"disableDebitCards,omitempty"`
+	DisableManual     bool `json:"disableManual,omitempty"`
+	DisableChip       bool `json:"disableEMVCT,omitempty"`
+	DisableSwipe      bool `json:"disableMSR,omitempty"`
+	// Items are what was bought, see WithItems.
+	Items []LineItem `json:"items,omitempty"`
+	// SurchargeAmount is the card surcharge included in PurchaseAmount, see
+	// WithSurcharge.
+	SurchargeAmount int64 `json:"-"`
+}
+
+// NewPayment creates a sale payment fragment for the given amount.
+func NewPayment(amount money.Money, referenceID string) *Payment {
+	return &Payment{
+		Action: ActionSale,
+		// Let the cardholder choose debit or credit unless WithPolicy says
+		// otherwise.
+		IsDebit: false,
+		// Amounts are sent as a Java long of the currency's minor units.
+		PurchaseAmount: amount.Amount,
+		TipAmount:      0,    // No tip unless WithTip says otherwise.
+		DisableTip:     true, // We don't tip in New Zealand.
+		CurrencyCode:   amount.Currency,
+		ReferenceID:    referenceID, // ReferenceID generated for each transaction.
+		// Need to use saleID.
+		// Will not have this when Weggie starts generating it server-side.
+		// Could use register_id, but that would not be changing per transaction.
+		// Could combine register_id with another changing param.
+		OrderID:     "test-order-123",
+		CallBackURL: callbackURL,
+	}
+}
+
+// NewPreAuth creates a payment fragment that only authorizes the given amount,
+// to open a tab that is captured when it is closed.
+func NewPreAuth(amount money.Money, referenceID string) *Payment {
+	payment := NewPayment(amount, referenceID)
+	payment.Action = ActionAuthorize
+	return payment
+}
+
+// NewRefund creates a card-present refund fragment for the given amount,
+// optionally against the original transaction.
+func NewRefund(amount money.Money, referenceID, transactionID string) *Payment {
+	payment := NewPayment(amount, referenceID)
+	payment.Action = ActionRefund
+	payment.TransactionID = transactionID
+	return payment
+}
+
+// Amount returns the purchase amount of the payment.
+func (p *Payment) Amount() money.Money {
+	return money.New(p.PurchaseAmount, p.CurrencyCode)
+}
+
+// SendPayment sends a payment fragment to the configured terminal and returns
+// the cloud message ID. The terminal must pick the message up within ttl.
+func SendPayment(ctx context.Context, c *client.Client, payment *Payment, ttl time.Duration) (string, error) {
+	message, err := NewMessage(c.Config, payment)
+	if err != nil {
+		return "", err
+	}
+	// Only one payment should ever be waiting on a terminal.
+	message.WithTTL(ttl).WithCollapseKey("payment")
+	return Send(ctx, c, message)
+}
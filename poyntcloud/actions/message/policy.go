@@ -0,0 +1,51 @@
+package poyntcloud
+
+import (
+	"fmt"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+)
+
+// Card types a payment policy can require.
+const (
+	CardDebit  = "debit"
+	CardCredit = "credit"
+	CardChoose = "choose"
+)
+
+// WithPolicy applies a store or register's payment policy to the payment,
+// deciding whether debit or credit is forced or left to the cardholder, and
+// which ways of presenting the card are allowed.
+func (p *Payment) WithPolicy(policy config.PaymentPolicy) error {
+	switch policy.CardType {
+	case CardDebit:
+		p.IsDebit = true
+		p.DisableDebitCards = false
+	case CardCredit:
+		p.IsDebit = false
+		p.DisableDebitCards = true
+	case CardChoose, "":
+		// The cardholder picks on the terminal.
+		p.IsDebit = false
+		p.DisableDebitCards = false
+	default:
+		return fmt.Errorf("unknown card type %q in payment policy", policy.CardType)
+	}
+
+	p.DisableManual = policy.DisableManualEntry || policy.ContactlessOnly
+	p.DisableChip = policy.ContactlessOnly
+	p.DisableSwipe = policy.ContactlessOnly
+	return nil
+}
+
+// CardType returns the card type the payment asks for: debit, credit or
+// choose.
+func (p *Payment) CardType() string {
+	switch {
+	case p.IsDebit:
+		return CardDebit
+	case p.DisableDebitCards:
+		return CardCredit
+	}
+	return CardChoose
+}
@@ -0,0 +1,59 @@
+package poyntcloud
+
+import (
+	"context"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Kinds of input the terminal can prompt the customer for.
+const (
+	PromptEmail = "EMAIL"
+	PromptPhone = "PHONE"
+	PromptYesNo = "YES_NO"
+	PromptText  = "TEXT"
+)
+
+// Prompt asks the customer for input on the terminal. The terminal app posts
+// the answer to CallBackURL with the prompt's ReferenceID, the same way it
+// returns a payment result.
+type Prompt struct {
+	Action      string `json:"action"`
+	Type        string `json:"type"`
+	Question    string `json:"question"`
+	ReferenceID string `json:"referenceId"`
+	CallBackURL string `json:"callbackUrl"`
+}
+
+// NewPrompt creates a prompt of the given type. The question is shown above the
+// input, e.g. "Email your receipt?".
+func NewPrompt(promptType, question, referenceID string) *Prompt {
+	return &Prompt{
+		Action:      "prompt",
+		Type:        promptType,
+		Question:    question,
+		ReferenceID: referenceID,
+		CallBackURL: callbackURL,
+	}
+}
+
+// ValidPromptType reports whether the terminal app knows the prompt type.
+func ValidPromptType(promptType string) bool {
+	switch promptType {
+	case PromptEmail, PromptPhone, PromptYesNo, PromptText:
+		return true
+	}
+	return false
+}
+
+// SendPrompt sends a prompt to the configured terminal and returns the cloud
+// message ID. The terminal must pick the message up within ttl.
+func SendPrompt(ctx context.Context, c *client.Client, prompt *Prompt, ttl time.Duration) (string, error) {
+	message, err := NewMessage(c.Config, prompt)
+	if err != nil {
+		return "", err
+	}
+	message.WithTTL(ttl)
+	return Send(ctx, c, message)
+}
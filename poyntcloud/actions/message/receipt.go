@@ -0,0 +1,30 @@
+package poyntcloud
+
+import (
+	"context"
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Receipt has the terminal print the receipt for a transaction it processed.
+type Receipt struct {
+	Action        string `json:"action"`
+	TransactionID string `json:"transactionId"`
+	ReferenceID   string `json:"referenceId,omitempty"`
+}
+
+// NewReceipt creates a print receipt action for the given transaction.
+func NewReceipt(transactionID, referenceID string) *Receipt {
+	return &Receipt{Action: "print", TransactionID: transactionID, ReferenceID: referenceID}
+}
+
+// PrintReceipt sends a receipt to the configured terminal's printer and returns
+// the cloud message ID.
+func PrintReceipt(ctx context.Context, c *client.Client, receipt *Receipt) (string, error) {
+	message, err := NewMessage(c.Config, receipt)
+	if err != nil {
+		return "", err
+	}
+	// A reprint is only wanted while the customer is still there.
+	message.WithTTL(displayTTL)
+	return Send(ctx, c, message)
+}
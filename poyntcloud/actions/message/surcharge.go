@@ -0,0 +1,27 @@
+package poyntcloud
+
+import (
+	"errors"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// WithSurcharge adds a card surcharge to the purchase amount, and as a line of
+// its own if the payment has items. It replaces any surcharge added before.
+func (p *Payment) WithSurcharge(fee money.Money) error {
+	if fee.Currency != p.CurrencyCode {
+		return money.ErrCurrencyMismatch
+	}
+	if fee.Amount < 0 {
+		return errors.New("surcharge must not be negative")
+	}
+	p.PurchaseAmount += fee.Amount - p.SurchargeAmount
+	p.SurchargeAmount = fee.Amount
+	p.setSurchargeLine()
+	return nil
+}
+
+// Surcharge returns the card surcharge included in the purchase amount.
+func (p *Payment) Surcharge() money.Money {
+	return money.New(p.SurchargeAmount, p.CurrencyCode)
+}
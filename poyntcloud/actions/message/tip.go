@@ -0,0 +1,63 @@
+package poyntcloud
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Tip modes a store can be configured with.
+const (
+	// TipNone takes no tip.
+	TipNone = "none"
+	// TipFixed adds a tip amount passed from the POS.
+	TipFixed = "fixed"
+	// TipPrompt asks the customer to choose a tip on the terminal.
+	TipPrompt = "prompt"
+)
+
+// DefaultTipPercentages are offered on the terminal when a store prompts for a
+// tip without configuring its own presets.
+var DefaultTipPercentages = []int{15, 18, 20}
+
+// WithTip sets how the payment is tipped. The fixed tip is only used in
+// TipFixed mode and the percentages only in TipPrompt mode.
+func (p *Payment) WithTip(mode string, fixed money.Money, percentages []int) error {
+	switch mode {
+	case TipNone, "":
+		p.TipAmount = 0
+		p.DisableTip = true
+		p.TipPercentages = nil
+	case TipFixed:
+		if fixed.Currency != p.CurrencyCode {
+			return money.ErrCurrencyMismatch
+		}
+		if fixed.Amount < 0 {
+			return errors.New("tip amount must not be negative")
+		}
+		p.TipAmount = fixed.Amount
+		p.DisableTip = true
+		p.TipPercentages = nil
+	case TipPrompt:
+		if len(percentages) == 0 {
+			percentages = DefaultTipPercentages
+		}
+		for _, percentage := range percentages {
+			if percentage <= 0 || percentage > 100 {
+				return fmt.Errorf("invalid tip percentage %d", percentage)
+			}
+		}
+		p.TipAmount = 0
+		p.DisableTip = false
+		p.TipPercentages = percentages
+	default:
+		return fmt.Errorf("unknown tip mode %q", mode)
+	}
+	return nil
+}
+
+// Tip returns the tip amount of the payment.
+func (p *Payment) Tip() money.Money {
+	return money.New(p.TipAmount, p.CurrencyCode)
+}
@@ -0,0 +1,127 @@
+package poyntcloud
+
+import (
+	"sync"
+	"time"
+)
+
+// Lifecycle of a sent cloud message.
+const (
+	StatusSent         = "SENT"
+	StatusAcknowledged = "ACKNOWLEDGED"
+	StatusExpired      = "EXPIRED"
+)
+
+// Delivery is the lifecycle of one cloud message, keyed by the reference ID of
+// its payload.
+type Delivery struct {
+	MessageID      string    `json:"messageId"`
+	ReferenceID    string    `json:"referenceId"`
+	Status         string    `json:"status"`
+	SentAt         time.Time `json:"sentAt"`
+	ExpiresAt      time.Time `json:"expiresAt"`
+	AcknowledgedAt time.Time `json:"acknowledgedAt,omitempty"`
+
+	expired chan struct{}
+	timer   *time.Timer
+}
+
+// Tracker follows sent cloud messages until they are acknowledged, or until
+// their TTL passes without that happening. Any call back from the terminal app
+// or transaction made for the message acknowledges it. An expired message was
+// only dropped undelivered if the app calls back as soon as it receives a
+// message; an app that only calls back with the result may still be working
+// on it.
+type Tracker struct {
+	mutex      sync.Mutex
+	deliveries map[string]*Delivery
+}
+
+// NewTracker creates an empty tracker.
+func NewTracker() *Tracker {
+	return &Tracker{deliveries: map[string]*Delivery{}}
+}
+
+// Sent starts tracking a message that was sent with the given TTL.
+func (t *Tracker) Sent(referenceID, messageID string, ttl time.Duration) {
+	if ttl <= 0 {
+		ttl = DefaultTTL
+	}
+	now := time.Now()
+	delivery := &Delivery{
+		MessageID:   messageID,
+		ReferenceID: referenceID,
+		Status:      StatusSent,
+		SentAt:      now,
+		ExpiresAt:   now.Add(ttl),
+		expired:     make(chan struct{}),
+	}
+	delivery.timer = time.AfterFunc(ttl, func() { t.expire(referenceID, delivery) })
+
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if previous, ok := t.deliveries[referenceID]; ok {
+		previous.timer.Stop()
+	}
+	t.deliveries[referenceID] = delivery
+}
+
+// expire marks the delivery expired if the terminal never acknowledged it.
+func (t *Tracker) expire(referenceID string, delivery *Delivery) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if delivery.Status != StatusSent {
+		return
+	}
+	delivery.Status = StatusExpired
+	close(delivery.expired)
+}
+
+// Acknowledge records that the terminal received the message and reports
+// whether it was being tracked.
+func (t *Tracker) Acknowledge(referenceID string) bool {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delivery, ok := t.deliveries[referenceID]
+	if !ok {
+		return false
+	}
+	if delivery.Status == StatusSent {
+		delivery.timer.Stop()
+		delivery.Status = StatusAcknowledged
+		delivery.AcknowledgedAt = time.Now()
+	}
+	return true
+}
+
+// Expired returns a channel that is closed if the message expires unseen. It
+// returns nil, which never receives, for an untracked message.
+func (t *Tracker) Expired(referenceID string) <-chan struct{} {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if delivery, ok := t.deliveries[referenceID]; ok {
+		return delivery.expired
+	}
+	return nil
+}
+
+// Get returns a copy of the delivery of the message with the given reference ID.
+func (t *Tracker) Get(referenceID string) (Delivery, bool) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	delivery, ok := t.deliveries[referenceID]
+	if !ok {
+		return Delivery{}, false
+	}
+	return *delivery, true
+}
+
+// Forget stops tracking the message with the given reference ID.
+func (t *Tracker) Forget(referenceID string) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+	if delivery, ok := t.deliveries[referenceID]; ok {
+		delivery.timer.Stop()
+		delete(t.deliveries, referenceID)
+	}
+}
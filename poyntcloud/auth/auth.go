@@ -125,12 +125,15 @@ func authRequest(params url.Values, config *config.Configuration) ([]byte, int,
 	req.Header.Set("Poynt-Request-Id", requestID)
 	req.Header.Set("User-Agent", "go-poynt")
 
-	client := &http.Client{}
+	// A token refresh holds up every request, so it must not hang.
+	client := &http.Client{Timeout: 30 * time.Second}
 	fmt.Println("Requesting access token from POYNT")
 	resp, err := client.Do(req)
 	if err != nil {
 		fmt.Println("Error performing HTTP request:", err)
+		return nil, 0, err
 	}
+	defer resp.Body.Close()
 
 	body, err := ioutil.ReadAll(resp.Body)
 	if err != nil {
@@ -0,0 +1,90 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Catalog is a set of products shown on the business's terminals.
+type Catalog struct {
+	ID         string        `json:"id,omitempty"`
+	BusinessID string        `json:"businessId,omitempty"`
+	Name       string        `json:"name"`
+	Products   []CatalogItem `json:"products,omitempty"`
+	CreatedAt  time.Time     `json:"createdAt,omitempty"`
+	UpdatedAt  time.Time     `json:"updatedAt,omitempty"`
+}
+
+// CatalogItem places a product in a catalog.
+type CatalogItem struct {
+	ID           string `json:"id"`
+	DisplayOrder int    `json:"displayOrder,omitempty"`
+}
+
+// Contains reports whether the product with the given ID is in the catalog.
+func (catalog *Catalog) Contains(productID string) bool {
+	for _, item := range catalog.Products {
+		if item.ID == productID {
+			return true
+		}
+	}
+	return false
+}
+
+// catalogList is a page of catalogs.
+type catalogList struct {
+	Catalogs []Catalog `json:"catalogs"`
+}
+
+// ListCatalogs returns the catalogs of the business.
+func ListCatalogs(ctx context.Context, c *client.Client) ([]Catalog, error) {
+	list := catalogList{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("catalogs"), nil, nil, &list); err != nil {
+		return nil, fmt.Errorf("error listing catalogs: %s", err)
+	}
+	return list.Catalogs, nil
+}
+
+// GetCatalog returns the catalog with the given ID.
+func GetCatalog(ctx context.Context, c *client.Client, id string) (*Catalog, error) {
+	catalog := Catalog{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("catalogs", id), nil, nil, &catalog); err != nil {
+		return nil, fmt.Errorf("error getting catalog %s: %s", id, err)
+	}
+	return &catalog, nil
+}
+
+// CreateCatalog adds a new catalog to the business.
+func CreateCatalog(ctx context.Context, c *client.Client, catalog *Catalog) (*Catalog, error) {
+	created := Catalog{}
+	catalog.BusinessID = c.Config.BusinessID
+	if err := c.Do(ctx, "POST", c.BusinessPath("catalogs"), nil, catalog, &created); err != nil {
+		return nil, fmt.Errorf("error creating catalog %s: %s", catalog.Name, err)
+	}
+	return &created, nil
+}
+
+// UpdateCatalog replaces the name and products of an existing catalog.
+func UpdateCatalog(ctx context.Context, c *client.Client, catalog *Catalog) (*Catalog, error) {
+	patch := []client.PatchOperation{
+		client.AddOperation("/name", catalog.Name),
+		client.AddOperation("/products", catalog.Products),
+	}
+
+	updated := Catalog{}
+	if err := c.Do(ctx, "PATCH", c.BusinessPath("catalogs", catalog.ID), nil, patch, &updated); err != nil {
+		return nil, fmt.Errorf("error updating catalog %s: %s", catalog.ID, err)
+	}
+	return &updated, nil
+}
+
+// DeleteCatalog removes the catalog with the given ID. Its products are kept.
+func DeleteCatalog(ctx context.Context, c *client.Client, id string) error {
+	if err := c.Do(ctx, "DELETE", c.BusinessPath("catalogs", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting catalog %s: %s", id, err)
+	}
+	return nil
+}
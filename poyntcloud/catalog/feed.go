@@ -0,0 +1,116 @@
+package catalog
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// FeedItem is a product as exported from the POS.
+type FeedItem struct {
+	SKU         string      `json:"sku"`
+	Name        string      `json:"name"`
+	Description string      `json:"description"`
+	Price       json.Number `json:"price"` // Decimal string, e.g. "19.99".
+	Currency    string      `json:"currency"`
+}
+
+// Product converts the feed item into a POYNT product.
+func (item FeedItem) Product() (Product, error) {
+	price, err := money.Parse(item.Price.String(), item.Currency)
+	if err != nil {
+		return Product{}, fmt.Errorf("sku %s: %s", item.SKU, err)
+	}
+	return Product{
+		Name:        item.Name,
+		SKU:         item.SKU,
+		Description: item.Description,
+		Price:       price,
+	}, nil
+}
+
+// LoadFeed reads a product feed from a .json or .csv file. Items without a
+// currency are given the default currency.
+func LoadFeed(path, currency string) ([]FeedItem, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("error opening product feed: %s", err)
+	}
+	defer file.Close()
+
+	var items []FeedItem
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		items, err = ReadJSONFeed(file)
+	case ".csv":
+		items, err = ReadCSVFeed(file)
+	default:
+		return nil, fmt.Errorf("unknown product feed format %q", filepath.Ext(path))
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range items {
+		if items[i].Currency == "" {
+			items[i].Currency = currency
+		}
+	}
+	return items, nil
+}
+
+// ReadJSONFeed reads a JSON array of feed items.
+func ReadJSONFeed(r io.Reader) ([]FeedItem, error) {
+	var items []FeedItem
+	if err := json.NewDecoder(r).Decode(&items); err != nil {
+		return nil, fmt.Errorf("error decoding JSON product feed: %s", err)
+	}
+	return items, nil
+}
+
+// ReadCSVFeed reads a CSV feed. The first row is a header naming the columns,
+// of which sku, name and price are required and description and currency are
+// optional.
+func ReadCSVFeed(r io.Reader) ([]FeedItem, error) {
+	rows, err := csv.NewReader(r).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("error reading CSV product feed: %s", err)
+	}
+	if len(rows) == 0 {
+		return nil, nil
+	}
+
+	columns := map[string]int{}
+	for i, name := range rows[0] {
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{"sku", "name", "price"} {
+		if _, ok := columns[required]; !ok {
+			return nil, fmt.Errorf("CSV product feed has no %q column", required)
+		}
+	}
+	field := func(row []string, name string) string {
+		if i, ok := columns[name]; ok && i < len(row) {
+			return strings.TrimSpace(row[i])
+		}
+		return ""
+	}
+
+	items := make([]FeedItem, 0, len(rows)-1)
+	for _, row := range rows[1:] {
+		items = append(items, FeedItem{
+			SKU:         field(row, "sku"),
+			Name:        field(row, "name"),
+			Description: field(row, "description"),
+			Price:       json.Number(field(row, "price")),
+			Currency:    field(row, "currency"),
+		})
+	}
+	return items, nil
+}
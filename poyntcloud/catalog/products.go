@@ -0,0 +1,102 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Number of products requested per page when listing.
+const pageSize = 100
+
+// Product is an item the business sells.
+type Product struct {
+	ID          string      `json:"id,omitempty"`
+	BusinessID  string      `json:"businessId,omitempty"`
+	Name        string      `json:"name"`
+	SKU         string      `json:"sku"`
+	ShortCode   string      `json:"shortCode,omitempty"`
+	Description string      `json:"description,omitempty"`
+	Price       money.Money `json:"price"`
+	Status      string      `json:"status,omitempty"` // ACTIVE
+	Type        string      `json:"type,omitempty"`   // SIMPLE
+	CreatedAt   time.Time   `json:"createdAt,omitempty"`
+	UpdatedAt   time.Time   `json:"updatedAt,omitempty"`
+}
+
+// productList is a page of products.
+type productList struct {
+	Products []Product `json:"products"`
+}
+
+// ListProducts returns every product of the business.
+func ListProducts(ctx context.Context, c *client.Client) ([]Product, error) {
+	var products []Product
+	for offset := 0; ; offset += pageSize {
+		query := url.Values{}
+		query.Add("startOffset", strconv.Itoa(offset))
+		query.Add("limit", strconv.Itoa(pageSize))
+
+		page := productList{}
+		if err := c.Do(ctx, "GET", c.BusinessPath("products"), query, nil, &page); err != nil {
+			return nil, fmt.Errorf("error listing products: %s", err)
+		}
+		products = append(products, page.Products...)
+		if len(page.Products) < pageSize {
+			return products, nil
+		}
+	}
+}
+
+// GetProduct returns the product with the given ID.
+func GetProduct(ctx context.Context, c *client.Client, id string) (*Product, error) {
+	product := Product{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("products", id), nil, nil, &product); err != nil {
+		return nil, fmt.Errorf("error getting product %s: %s", id, err)
+	}
+	return &product, nil
+}
+
+// CreateProduct adds a new product to the business.
+func CreateProduct(ctx context.Context, c *client.Client, product *Product) (*Product, error) {
+	created := Product{}
+	product.BusinessID = c.Config.BusinessID
+	if product.Status == "" {
+		product.Status = "ACTIVE"
+	}
+	if product.Type == "" {
+		product.Type = "SIMPLE"
+	}
+	if err := c.Do(ctx, "POST", c.BusinessPath("products"), nil, product, &created); err != nil {
+		return nil, fmt.Errorf("error creating product %s: %s", product.SKU, err)
+	}
+	return &created, nil
+}
+
+// UpdateProduct sets the name, description and price of an existing product.
+func UpdateProduct(ctx context.Context, c *client.Client, product *Product) (*Product, error) {
+	patch := []client.PatchOperation{
+		client.AddOperation("/name", product.Name),
+		client.AddOperation("/description", product.Description),
+		client.AddOperation("/price", product.Price),
+	}
+
+	updated := Product{}
+	if err := c.Do(ctx, "PATCH", c.BusinessPath("products", product.ID), nil, patch, &updated); err != nil {
+		return nil, fmt.Errorf("error updating product %s: %s", product.SKU, err)
+	}
+	return &updated, nil
+}
+
+// DeleteProduct removes the product with the given ID.
+func DeleteProduct(ctx context.Context, c *client.Client, id string) error {
+	if err := c.Do(ctx, "DELETE", c.BusinessPath("products", id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting product %s: %s", id, err)
+	}
+	return nil
+}
@@ -0,0 +1,189 @@
+package catalog
+
+import (
+	"context"
+	"fmt"
+	"io"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Actions a sync plan can take on a product.
+const (
+	ActionCreate = "create"
+	ActionUpdate = "update"
+	ActionAdd    = "add"
+	ActionRemove = "remove"
+)
+
+// SyncOptions controls how a product feed is reconciled into a catalog.
+type SyncOptions struct {
+	// DryRun works out the changes without making them.
+	DryRun bool
+	// RemoveMissing takes catalog products whose SKU is not in the feed out of
+	// the catalog. The products themselves are kept, as other catalogs may
+	// list them. Products without a SKU are left alone.
+	RemoveMissing bool
+}
+
+// Change is a single difference between the feed and POYNT.
+type Change struct {
+	Action  string
+	SKU     string
+	Product Product
+	// Fields lists what differs for an update.
+	Fields []string
+}
+
+// Plan is the set of changes needed to make a catalog match a feed.
+type Plan struct {
+	CatalogID string
+	Changes   []Change
+	Unchanged int
+}
+
+// Print writes a human readable diff of the plan.
+func (plan *Plan) Print(w io.Writer) {
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionUpdate:
+			fmt.Fprintf(w, "~ %s %s %v\n", change.SKU, change.Product.Name, change.Fields)
+		case ActionCreate:
+			fmt.Fprintf(w, "+ %s %s\n", change.SKU, change.Product.Name)
+		case ActionAdd:
+			fmt.Fprintf(w, "> %s %s (add to catalog)\n", change.SKU, change.Product.Name)
+		case ActionRemove:
+			fmt.Fprintf(w, "- %s %s (remove from catalog)\n", change.SKU, change.Product.Name)
+		}
+	}
+	fmt.Fprintf(w, "%d changes, %d unchanged\n", len(plan.Changes), plan.Unchanged)
+}
+
+// Diff works out the changes needed to make the catalog match the feed.
+// Products are matched by SKU across the whole business, so a product that
+// already exists is reused rather than duplicated.
+func Diff(feed []FeedItem, products []Product, catalog *Catalog, options SyncOptions) (*Plan, error) {
+	bySKU := map[string]Product{}
+	for _, product := range products {
+		if product.SKU != "" {
+			bySKU[product.SKU] = product
+		}
+	}
+
+	plan := &Plan{CatalogID: catalog.ID}
+	seen := map[string]bool{}
+	for _, item := range feed {
+		if item.SKU == "" {
+			return nil, fmt.Errorf("product %q in feed has no SKU", item.Name)
+		}
+		if seen[item.SKU] {
+			return nil, fmt.Errorf("sku %s appears more than once in feed", item.SKU)
+		}
+		seen[item.SKU] = true
+
+		want, err := item.Product()
+		if err != nil {
+			return nil, err
+		}
+		existing, ok := bySKU[item.SKU]
+		if !ok {
+			plan.Changes = append(plan.Changes, Change{Action: ActionCreate, SKU: item.SKU, Product: want})
+			continue
+		}
+
+		want.ID = existing.ID
+		if fields := changedFields(existing, want); len(fields) > 0 {
+			plan.Changes = append(plan.Changes, Change{ActionUpdate, item.SKU, want, fields})
+		} else if catalog.Contains(existing.ID) {
+			plan.Unchanged++
+		}
+		if !catalog.Contains(existing.ID) {
+			plan.Changes = append(plan.Changes, Change{Action: ActionAdd, SKU: item.SKU, Product: want})
+		}
+	}
+
+	if options.RemoveMissing {
+		for _, product := range products {
+			if product.SKU != "" && catalog.Contains(product.ID) && !seen[product.SKU] {
+				plan.Changes = append(plan.Changes, Change{Action: ActionRemove, SKU: product.SKU, Product: product})
+			}
+		}
+	}
+	return plan, nil
+}
+
+// changedFields lists the fields the feed would change on a product.
+func changedFields(have, want Product) []string {
+	var fields []string
+	if have.Name != want.Name {
+		fields = append(fields, "name")
+	}
+	if have.Description != want.Description {
+		fields = append(fields, "description")
+	}
+	if have.Price != want.Price {
+		fields = append(fields, "price")
+	}
+	return fields
+}
+
+// Apply makes the changes in the plan, then updates the catalog's product list
+// once at the end. Removed products only leave the catalog and are not deleted.
+func (plan *Plan) Apply(ctx context.Context, c *client.Client, catalog *Catalog) error {
+	items := catalog.Products
+	removed := map[string]bool{}
+
+	for _, change := range plan.Changes {
+		switch change.Action {
+		case ActionCreate:
+			created, err := CreateProduct(ctx, c, &change.Product)
+			if err != nil {
+				return err
+			}
+			items = append(items, CatalogItem{ID: created.ID, DisplayOrder: len(items)})
+		case ActionUpdate:
+			if _, err := UpdateProduct(ctx, c, &change.Product); err != nil {
+				return err
+			}
+		case ActionAdd:
+			items = append(items, CatalogItem{ID: change.Product.ID, DisplayOrder: len(items)})
+		case ActionRemove:
+			removed[change.Product.ID] = true
+		}
+	}
+
+	kept := items[:0]
+	for _, item := range items {
+		if !removed[item.ID] {
+			kept = append(kept, item)
+		}
+	}
+	if len(kept) == len(catalog.Products) && len(removed) == 0 {
+		return nil
+	}
+	catalog.Products = kept
+	_, err := UpdateCatalog(ctx, c, catalog)
+	return err
+}
+
+// Sync reconciles a product feed into the catalog with the given ID and
+// returns the plan it followed. With DryRun set nothing is changed.
+func Sync(ctx context.Context, c *client.Client, feed []FeedItem, catalogID string, options SyncOptions) (*Plan, error) {
+	catalog, err := GetCatalog(ctx, c, catalogID)
+	if err != nil {
+		return nil, err
+	}
+	products, err := ListProducts(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+
+	plan, err := Diff(feed, products, catalog, options)
+	if err != nil {
+		return nil, err
+	}
+	if options.DryRun {
+		return plan, nil
+	}
+	return plan, plan.Apply(ctx, c, catalog)
+}
@@ -0,0 +1,113 @@
+package catalog
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+func TestDiff(t *testing.T) {
+	products := []Product{
+		{ID: "p1", SKU: "A", Name: "Apple", Price: money.New(100, "NZD")},
+		{ID: "p2", SKU: "B", Name: "Banana", Price: money.New(200, "NZD")},
+		{ID: "p3", SKU: "C", Name: "Cherry", Price: money.New(300, "NZD")},
+		{ID: "p4", SKU: "", Name: "Gift wrap", Price: money.New(50, "NZD")},
+	}
+	catalog := &Catalog{ID: "cat", Products: []CatalogItem{{ID: "p1"}, {ID: "p3"}, {ID: "p4"}}}
+
+	tests := []struct {
+		name      string
+		feed      []FeedItem
+		options   SyncOptions
+		changes   []string // Action and SKU of each change
+		unchanged int
+	}{
+		{
+			name: "unchanged",
+			feed: []FeedItem{
+				{SKU: "A", Name: "Apple", Price: "1.00", Currency: "NZD"},
+				{SKU: "C", Name: "Cherry", Price: "3.00", Currency: "NZD"},
+			},
+			unchanged: 2,
+		},
+		{
+			name: "create new sku",
+			feed: []FeedItem{
+				{SKU: "A", Name: "Apple", Price: "1.00", Currency: "NZD"},
+				{SKU: "D", Name: "Date", Price: "4.00", Currency: "NZD"},
+			},
+			changes:   []string{"create D"},
+			unchanged: 1,
+		},
+		{
+			name: "update changed price",
+			feed: []FeedItem{
+				{SKU: "A", Name: "Apple", Price: "1.10", Currency: "NZD"},
+			},
+			changes: []string{"update A"},
+		},
+		{
+			name: "add existing product to catalog",
+			feed: []FeedItem{
+				{SKU: "B", Name: "Banana", Price: "2.00", Currency: "NZD"},
+			},
+			changes: []string{"add B"},
+		},
+		{
+			name: "missing products kept without remove",
+			feed: []FeedItem{
+				{SKU: "A", Name: "Apple", Price: "1.00", Currency: "NZD"},
+			},
+			unchanged: 1,
+		},
+		{
+			name: "remove missing skips products without sku",
+			feed: []FeedItem{
+				{SKU: "A", Name: "Apple", Price: "1.00", Currency: "NZD"},
+			},
+			options:   SyncOptions{RemoveMissing: true},
+			changes:   []string{"remove C"},
+			unchanged: 1,
+		},
+	}
+
+	for _, test := range tests {
+		plan, err := Diff(test.feed, products, catalog, test.options)
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		var changes []string
+		for _, change := range plan.Changes {
+			changes = append(changes, change.Action+" "+change.SKU)
+		}
+		if !reflect.DeepEqual(changes, test.changes) {
+			t.Errorf("%s: changes = %v, want %v", test.name, changes, test.changes)
+		}
+		if plan.Unchanged != test.unchanged {
+			t.Errorf("%s: unchanged = %d, want %d", test.name, plan.Unchanged, test.unchanged)
+		}
+	}
+}
+
+func TestDiffInvalidFeed(t *testing.T) {
+	catalog := &Catalog{ID: "cat"}
+	tests := []struct {
+		name string
+		feed []FeedItem
+	}{
+		{"missing sku", []FeedItem{{Name: "Apple", Price: "1.00", Currency: "NZD"}}},
+		{"duplicate sku", []FeedItem{
+			{SKU: "A", Name: "Apple", Price: "1.00", Currency: "NZD"},
+			{SKU: "A", Name: "Apricot", Price: "2.00", Currency: "NZD"},
+		}},
+		{"bad price", []FeedItem{{SKU: "A", Name: "Apple", Price: "1.005", Currency: "NZD"}}},
+	}
+
+	for _, test := range tests {
+		if _, err := Diff(test.feed, nil, catalog, SyncOptions{}); err == nil {
+			t.Errorf("%s: expected an error", test.name)
+		}
+	}
+}
@@ -0,0 +1,178 @@
+package client
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/url"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud"
+	"github.com/jtrotsky/go-poynt/poyntcloud/auth"
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+)
+
+// Client performs authenticated requests against the POYNT cloud API.
+type Client struct {
+	Config     *config.Configuration
+	Creds      *auth.OAuthCreds
+	HTTPClient *http.Client
+
+	// credsMutex guards Creds, which are replaced on refresh while other
+	// requests may be in flight.
+	credsMutex sync.Mutex
+}
+
+// requestTimeout is the longest a request to POYNT may take, so a caller is
+// never stuck on an unresponsive API.
+const requestTimeout = 30 * time.Second
+
+// NewClient creates a client that uses the given configuration and credentials.
+func NewClient(config *config.Configuration, creds *auth.OAuthCreds) *Client {
+	return &Client{Config: config, Creds: creds, HTTPClient: &http.Client{Timeout: requestTimeout}}
+}
+
+// APIError is returned when POYNT responds with a non 2xx status.
+type APIError struct {
+	StatusCode int
+	Response   auth.Response
+	Body       []byte
+}
+
+func (e *APIError) Error() string {
+	if e.Response.Message != "" {
+		return fmt.Sprintf("poynt: %d %s: %s", e.StatusCode, e.Response.Code,
+			e.Response.Message)
+	}
+	return fmt.Sprintf("poynt: %d %s", e.StatusCode, e.Body)
+}
+
+// Do sends a request to the given API path. The payload, if any, is sent as
+// JSON and a successful response body is unmarshalled into result, if given.
+// An expired access token is refreshed once and the request retried. The
+// request is given up when ctx is done.
+func (c *Client) Do(ctx context.Context, method, path string, query url.Values, payload, result interface{}) error {
+	creds := c.creds()
+	body, err := c.do(ctx, method, path, query, payload, creds)
+	if apiErr, ok := err.(*APIError); ok && apiErr.StatusCode == http.StatusUnauthorized {
+		if creds, err = c.refresh(creds); err != nil {
+			return apiErr
+		}
+		body, err = c.do(ctx, method, path, query, payload, creds)
+	}
+	if err != nil {
+		return err
+	}
+
+	if result == nil || len(body) == 0 {
+		return nil
+	}
+	if err = json.Unmarshal(body, result); err != nil {
+		return fmt.Errorf("error unmarshalling response from %s: %s", path, err)
+	}
+	return nil
+}
+
+// creds returns the current credentials.
+func (c *Client) creds() *auth.OAuthCreds {
+	c.credsMutex.Lock()
+	defer c.credsMutex.Unlock()
+	return c.Creds
+}
+
+// refresh replaces the rejected credentials with fresh ones. If another request
+// already refreshed them those are used instead.
+func (c *Client) refresh(rejected *auth.OAuthCreds) (*auth.OAuthCreds, error) {
+	c.credsMutex.Lock()
+	defer c.credsMutex.Unlock()
+	if c.Creds != rejected {
+		return c.Creds, nil
+	}
+
+	if c.Config.Debug {
+		log.Println("Refreshing access token")
+	}
+	creds, err := auth.RefreshAccessToken(c.Config, c.Creds)
+	if err != nil {
+		return nil, err
+	}
+	c.Creds = creds
+	return creds, nil
+}
+
+func (c *Client) do(ctx context.Context, method, path string, query url.Values, payload interface{}, creds *auth.OAuthCreds) ([]byte, error) {
+	address := c.Config.PoyntAPIHostURL + path
+	if len(query) > 0 {
+		address += "?" + query.Encode()
+	}
+
+	var reqBody *bytes.Buffer
+	if payload != nil {
+		payloadJSON, err := json.Marshal(payload)
+		if err != nil {
+			return nil, fmt.Errorf("error marshalling request payload: %s", err)
+		}
+		reqBody = bytes.NewBuffer(payloadJSON)
+	} else {
+		reqBody = &bytes.Buffer{}
+	}
+
+	req, err := http.NewRequest(method, address, reqBody)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %s", err)
+	}
+	req = req.WithContext(ctx)
+	req.Header.Set("Authorization", creds.TokenType+" "+creds.AccessToken)
+	req.Header.Set("api-version", strconv.FormatFloat(c.Config.PoyntAPIVersion, 'f', 1, 64))
+	req.Header.Set("Content-Type", "application/json")
+	// Create UUID for requestID
+	req.Header.Set("Poynt-Request-Id", poyntcloud.GenerateReferenceID())
+	req.Header.Set("User-Agent", "go-poynt")
+
+	resp, err := c.HTTPClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("error performing HTTP request: %s", err)
+	}
+	defer resp.Body.Close()
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("error reading response body: %s", err)
+	}
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		apiErr := &APIError{StatusCode: resp.StatusCode, Body: body}
+		json.Unmarshal(body, &apiErr.Response)
+		return nil, apiErr
+	}
+	return body, nil
+}
+
+// PatchOperation is a single JSON Patch operation, as accepted by POYNT when
+// updating resources with PATCH.
+type PatchOperation struct {
+	Op    string      `json:"op"`
+	Path  string      `json:"path"`
+	Value interface{} `json:"value,omitempty"`
+}
+
+// AddOperation returns a JSON Patch operation that sets the value at path.
+func AddOperation(path string, value interface{}) PatchOperation {
+	return PatchOperation{Op: "add", Path: path, Value: value}
+}
+
+// BusinessPath returns the API path for a resource under the configured
+// business, e.g. BusinessPath("customers") is /businesses/{businessId}/customers.
+func (c *Client) BusinessPath(elem ...string) string {
+	path := "/businesses/" + url.PathEscape(c.Config.BusinessID)
+	for _, e := range elem {
+		path += "/" + url.PathEscape(e)
+	}
+	return path
+}
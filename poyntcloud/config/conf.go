@@ -8,18 +8,71 @@ import (
 
 // Configuration is for API and application configuration
 type Configuration struct {
-	PackageName        string  `json:"package_name,omitempty"`          // com.vendhq.poyntlisten
-	ClassName          string  `json:"class_name,omitempty"`            // com.vendhq.poyntlisten.MainReceiverClass
-	PoyntAPIHostURL    string  `json:"poynt_api_host_url,omitempty"`    // https://services.poynt.net
-	PoyntAPIVersion    float64 `json:"poynt_api_version,omitempty"`     // 1.2
-	PoyntAuthHostURL   string  `json:"poynt_auth_host_url,omitempty"`   // https://poynt.net
-	BusinessID         string  `json:"business_id,omitempty"`           // c58ceb6f-3ecb-4000-84cf-f981f34ce482 Honest Mulch
-	StoreID            string  `json:"store_id,omitempty"`              // fa937f9f-4493-4941-bded-7c2db42e8c9a Honest Mulch 458
-	ApplicationID      string  `json:"application_id,omitempty"`        // urn:aid:67dae7d1-a503-443d-a000-6da70bc98743 Poynt Pay
-	DeviceID           string  `json:"device_id,omitempty"`             // l4zo
-	PrivateKeyFile     string  `json:"private_key_file,omitempty"`      // keys/poynt_pay_key
-	PublicKeyFile      string  `json:"public_key_file,omitempty"`       // keys/poynt_pay_key.pub
-	PoyntPublicKeyFile string  `json:"poynt_public_key_file,omitempty"` // keys/services.poynt.net.pub
+	PackageName        string                   `json:"package_name,omitempty"`          // com.vendhq.poyntlisten
+	ClassName          string                   `json:"class_name,omitempty"`            // com.vendhq.poyntlisten.MainReceiverClass
+	PoyntAPIHostURL    string                   `json:"poynt_api_host_url,omitempty"`    // https://services.poynt.net
+	PoyntAPIVersion    float64                  `json:"poynt_api_version,omitempty"`     // 1.2
+	PoyntAuthHostURL   string                   `json:"poynt_auth_host_url,omitempty"`   // https://poynt.net
+	BusinessID         string                   `json:"business_id,omitempty"`           // c58ceb6f-3ecb-4000-84cf-f981f34ce482 Honest Mulch
+	StoreID            string                   `json:"store_id,omitempty"`              // fa937f9f-4493-4941-bded-7c2db42e8c9a Honest Mulch 458
+	ApplicationID      string                   `json:"application_id,omitempty"`        // urn:aid:67dae7d1-a503-443d-a000-6da70bc98743 Poynt Pay
+	DeviceID           string                   `json:"device_id,omitempty"`             // l4zo
+	DeviceName         string                   `json:"device_name,omitempty"`           // Front Counter
+	Currency           string                   `json:"currency,omitempty"`              // NZD, leave empty to use the POYNT store's currency
+	PrivateKeyFile     string                   `json:"private_key_file,omitempty"`      // keys/poynt_pay_key
+	PublicKeyFile      string                   `json:"public_key_file,omitempty"`       // keys/poynt_pay_key.pub
+	PoyntPublicKeyFile string                   `json:"poynt_public_key_file,omitempty"` // keys/services.poynt.net.pub
+	TipMode            string                   `json:"tip_mode,omitempty"`              // none, fixed (amount from the POS) or prompt (on the terminal)
+	TipPercentages     []int                    `json:"tip_percentages,omitempty"`       // [15, 18, 20] presets offered when prompting
+	PaymentPolicy      PaymentPolicy            `json:"payment_policy,omitempty"`        // Card and tender rules for the store
+	RegisterPolicies   map[string]PaymentPolicy `json:"register_policies,omitempty"`     // Overrides by Vend register ID
+	CashBack           CashBack                 `json:"cash_back,omitempty"`             // Cash out on debit sales
+	Surcharge          Surcharge                `json:"surcharge,omitempty"`             // Card fees passed on to customers
+	MessageTTL         int                      `json:"message_ttl,omitempty"`           // 60 seconds a terminal has to pick up a cloud message
+	AppAcknowledges    bool                     `json:"app_acknowledges,omitempty"`      // true only if the terminal app calls back RECEIVED on getting a message, lets expired payments fail
+	PaymentTimeout     int                      `json:"payment_timeout,omitempty"`       // 180 seconds to wait for the terminal before a payment is UNKNOWN
+	WebhookSecret      string                   `json:"webhook_secret,omitempty"`        // Shared secret POYNT signs webhook deliveries with
+	Webhooks           []Hook                   `json:"webhooks,omitempty"`              // Webhook subscriptions to keep registered
+	Debug              bool                     `json:"debug,omitempty"`                 // true to log cloud messages and terminal callbacks, which hold customer details
+}
+
+// PaymentPolicy decides how a card may be used to pay.
+type PaymentPolicy struct {
+	CardType           string `json:"card_type,omitempty"`            // debit, credit or choose (the default)
+	ContactlessOnly    bool   `json:"contactless_only,omitempty"`     // Refuse chip insert, swipe and manual entry
+	DisableManualEntry bool   `json:"disable_manual_entry,omitempty"` // Refuse keyed card numbers
+}
+
+// CashBack limits the cash a customer may take out on a debit sale.
+type CashBack struct {
+	Enabled   bool   `json:"enabled,omitempty"`    // Off unless set
+	MaxAmount string `json:"max_amount,omitempty"` // 100.00 most cash out per sale, no limit if empty
+	Rounding  string `json:"rounding,omitempty"`   // 5.00 cash out is rounded down to a multiple of this
+}
+
+// Surcharge rules pass card fees on to the customer. Rates are in basis
+// points, 150 is 1.5%.
+type Surcharge struct {
+	Mode        string         `json:"mode,omitempty"`         // add (to the purchase amount) or prompt (customer accepts on the terminal), off if empty
+	BasisPoints int            `json:"basis_points,omitempty"` // 150 flat rate on any card
+	CardTypes   map[string]int `json:"card_types,omitempty"`   // {"AMEX": 300, "DEBIT": 0} rates by card brand or debit/credit
+	Region      string         `json:"region,omitempty"`       // US, CA, GB, EU, NZ or AU, required, the region's legal cap applies
+	Cap         int            `json:"cap,omitempty"`          // 250 basis points, only lowers the regional cap
+}
+
+// PolicyFor returns the payment policy of the given register, falling back to
+// the store's policy.
+func (c *Configuration) PolicyFor(registerID string) PaymentPolicy {
+	if policy, ok := c.RegisterPolicies[registerID]; ok {
+		return policy
+	}
+	return c.PaymentPolicy
+}
+
+// Hook is a desired webhook subscription.
+type Hook struct {
+	DeliveryURL string   `json:"delivery_url"` // https://736ed89f.ngrok.com/webhooks
+	EventTypes  []string `json:"event_types"`  // ["TRANSACTION_AUTHORIZED", "TRANSACTION_CAPTURED"]
 }
 
 // GetConfig creates a Configuration object from config JSON
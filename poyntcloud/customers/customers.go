@@ -0,0 +1,188 @@
+package customers
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Keys used in the Emails and Phones maps of a Customer.
+const (
+	EmailPersonal = "PERSONAL"
+	PhoneMobile   = "MOBILE"
+)
+
+// Customer is a shopper stored against the business in POYNT.
+type Customer struct {
+	ID         int64            `json:"id,omitempty"`
+	BusinessID string           `json:"businessId,omitempty"`
+	FirstName  string           `json:"firstName,omitempty"`
+	LastName   string           `json:"lastName,omitempty"`
+	Emails     map[string]Email `json:"emails,omitempty"`
+	Phones     map[string]Phone `json:"phones,omitempty"`
+	CreatedAt  time.Time        `json:"createdAt,omitempty"`
+	UpdatedAt  time.Time        `json:"updatedAt,omitempty"`
+}
+
+// Email is a customer email address.
+type Email struct {
+	EmailAddress string `json:"emailAddress"`
+	Primary      bool   `json:"primaryEmail,omitempty"`
+}
+
+// Phone is a customer phone number.
+type Phone struct {
+	LocalPhoneNumber string `json:"localPhoneNumber"`
+	AreaCode         string `json:"areaCode,omitempty"`
+	ITUCountryCode   string `json:"ituCountryCode,omitempty"`
+	Primary          bool   `json:"primaryPhone,omitempty"`
+}
+
+// Email returns the customer's personal email address, if any.
+func (c Customer) Email() string {
+	return c.Emails[EmailPersonal].EmailAddress
+}
+
+// Phone returns the customer's mobile phone number, if any.
+func (c Customer) Phone() string {
+	return c.Phones[PhoneMobile].LocalPhoneNumber
+}
+
+// customerList is the paged list returned when searching customers.
+type customerList struct {
+	Customers []Customer `json:"customers"`
+}
+
+// Search returns the customers of the business matching the given email address
+// or phone number. At least one must be given.
+func Search(ctx context.Context, c *client.Client, email, phone string) ([]Customer, error) {
+	if email == "" && phone == "" {
+		return nil, errors.New("an email address or phone number is required to search customers")
+	}
+	query := url.Values{}
+	if email != "" {
+		query.Add("email", email)
+	}
+	if phone != "" {
+		query.Add("phone", phone)
+	}
+
+	list := customerList{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("customers"), query, nil, &list); err != nil {
+		return nil, fmt.Errorf("error searching customers: %s", err)
+	}
+	return list.Customers, nil
+}
+
+// Get returns the customer with the given ID.
+func Get(ctx context.Context, c *client.Client, id int64) (*Customer, error) {
+	customer := Customer{}
+	path := c.BusinessPath("customers", strconv.FormatInt(id, 10))
+	if err := c.Do(ctx, "GET", path, nil, nil, &customer); err != nil {
+		return nil, fmt.Errorf("error getting customer %d: %s", id, err)
+	}
+	return &customer, nil
+}
+
+// Create adds a new customer to the business.
+func Create(ctx context.Context, c *client.Client, customer *Customer) (*Customer, error) {
+	created := Customer{}
+	customer.BusinessID = c.Config.BusinessID
+	if err := c.Do(ctx, "POST", c.BusinessPath("customers"), nil, customer, &created); err != nil {
+		return nil, fmt.Errorf("error creating customer: %s", err)
+	}
+	return &created, nil
+}
+
+// Update sets the name of an existing customer, and the emails and phones
+// under the keys of the given customer, to its non-empty values. Emails and
+// phones under other keys are kept.
+func Update(ctx context.Context, c *client.Client, customer *Customer) (*Customer, error) {
+	var patch []client.PatchOperation
+	if customer.FirstName != "" {
+		patch = append(patch, client.AddOperation("/firstName", customer.FirstName))
+	}
+	if customer.LastName != "" {
+		patch = append(patch, client.AddOperation("/lastName", customer.LastName))
+	}
+	for _, key := range sortedKeys(customer.Emails) {
+		patch = append(patch, client.AddOperation("/emails/"+key, customer.Emails[key]))
+	}
+	for _, key := range sortedKeys(customer.Phones) {
+		patch = append(patch, client.AddOperation("/phones/"+key, customer.Phones[key]))
+	}
+	if len(patch) == 0 {
+		return customer, nil
+	}
+
+	updated := Customer{}
+	path := c.BusinessPath("customers", strconv.FormatInt(customer.ID, 10))
+	if err := c.Do(ctx, "PATCH", path, nil, patch, &updated); err != nil {
+		return nil, fmt.Errorf("error updating customer %d: %s", customer.ID, err)
+	}
+	return &updated, nil
+}
+
+// Save looks the customer up by email, then phone, and updates the first match.
+// A new customer is created if none matches.
+func Save(ctx context.Context, c *client.Client, customer *Customer) (*Customer, error) {
+	var matches []Customer
+	var err error
+	if email := customer.Email(); email != "" {
+		if matches, err = Search(ctx, c, email, ""); err != nil {
+			return nil, err
+		}
+	}
+	if phone := customer.Phone(); len(matches) == 0 && phone != "" {
+		if matches, err = Search(ctx, c, "", phone); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(matches) == 0 {
+		return Create(ctx, c, customer)
+	}
+	customer.ID = matches[0].ID
+	return Update(ctx, c, customer)
+}
+
+// sortedKeys returns the keys of an Emails or Phones map in order, so patches
+// are the same every time.
+func sortedKeys(m interface{}) []string {
+	var keys []string
+	switch m := m.(type) {
+	case map[string]Email:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	case map[string]Phone:
+		for key := range m {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// New builds a customer from the details a POS passes with a sale. Empty values
+// are left out.
+func New(firstName, lastName, email, phone string) *Customer {
+	customer := &Customer{FirstName: firstName, LastName: lastName}
+	if email != "" {
+		customer.Emails = map[string]Email{
+			EmailPersonal: {EmailAddress: email, Primary: true},
+		}
+	}
+	if phone != "" {
+		customer.Phones = map[string]Phone{
+			PhoneMobile: {LocalPhoneNumber: phone, Primary: true},
+		}
+	}
+	return customer
+}
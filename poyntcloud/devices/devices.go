@@ -0,0 +1,74 @@
+package devices
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Device statuses reported by POYNT for a store device.
+const (
+	StatusCreated     = "CREATED"
+	StatusActivated   = "ACTIVATED"
+	StatusDeactivated = "DEACTIVATED"
+)
+
+// Device is a terminal registered to a store.
+type Device struct {
+	DeviceID     string    `json:"deviceId,omitempty"`
+	SerialNumber string    `json:"serialNumber,omitempty"`
+	Name         string    `json:"name,omitempty"`
+	Type         string    `json:"type,omitempty"`   // TERMINAL
+	Status       string    `json:"status,omitempty"` // CREATED, ACTIVATED, DEACTIVATED
+	StoreID      string    `json:"storeId,omitempty"`
+	BusinessID   string    `json:"businessId,omitempty"`
+	CreatedAt    time.Time `json:"createdAt,omitempty"`
+	UpdatedAt    time.Time `json:"updatedAt,omitempty"`
+	LastSeenAt   time.Time `json:"lastSeenAt,omitempty"`
+}
+
+// Online reports whether the device is activated and has been seen within the
+// given window.
+func (d Device) Online(window time.Duration) bool {
+	return d.Status == StatusActivated && !d.LastSeenAt.IsZero() &&
+		time.Since(d.LastSeenAt) < window
+}
+
+// List returns all devices registered to the given store.
+func List(ctx context.Context, c *client.Client, storeID string) ([]Device, error) {
+	var devices []Device
+	path := c.BusinessPath("stores", storeID, "storeDevices")
+	if err := c.Do(ctx, "GET", path, nil, nil, &devices); err != nil {
+		return nil, fmt.Errorf("error listing store devices: %s", err)
+	}
+	return devices, nil
+}
+
+// Resolve finds the device in the list matching the given name, serial number or
+// device ID. Names are matched without regard to case.
+func Resolve(devices []Device, name string) (*Device, error) {
+	for i, device := range devices {
+		if strings.EqualFold(device.Name, name) || device.SerialNumber == name ||
+			device.DeviceID == name {
+			return &devices[i], nil
+		}
+	}
+	return nil, fmt.Errorf("no device named %q in store", name)
+}
+
+// ResolveID lists the devices of the given store and returns the device ID of
+// the one matching name.
+func ResolveID(ctx context.Context, c *client.Client, storeID, name string) (string, error) {
+	devices, err := List(ctx, c, storeID)
+	if err != nil {
+		return "", err
+	}
+	device, err := Resolve(devices, name)
+	if err != nil {
+		return "", err
+	}
+	return device.DeviceID, nil
+}
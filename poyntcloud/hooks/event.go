@@ -0,0 +1,139 @@
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+// SignatureHeader is the HTTP header POYNT puts the delivery signature in.
+const SignatureHeader = "Poynt-Webhook-Signature"
+
+// Event is a webhook delivery. Resource is the API path of what changed, e.g.
+// /businesses/{businessId}/transactions/{transactionId}.
+type Event struct {
+	ID         string    `json:"id"`
+	EventType  string    `json:"eventType"`
+	Resource   string    `json:"resource"`
+	ResourceID string    `json:"resourceId"`
+	BusinessID string    `json:"businessId"`
+	StoreID    string    `json:"storeId,omitempty"`
+	DeviceID   string    `json:"deviceId,omitempty"`
+	HookID     string    `json:"hookId,omitempty"`
+	CreatedAt  time.Time `json:"createdAt"`
+	Links      []Link    `json:"links,omitempty"`
+}
+
+// Link points at the resource an event is about.
+type Link struct {
+	Href   string `json:"href"`
+	Rel    string `json:"rel"`
+	Method string `json:"method"`
+}
+
+// ParseEvent decodes a webhook delivery body.
+func ParseEvent(body []byte) (*Event, error) {
+	event := Event{}
+	if err := json.Unmarshal(body, &event); err != nil {
+		return nil, fmt.Errorf("error unmarshalling webhook event: %s", err)
+	}
+	if event.ID == "" || event.EventType == "" {
+		return nil, errors.New("webhook event has no id or eventType")
+	}
+	return &event, nil
+}
+
+// VerifySignature reports whether signature is the base64 encoded HMAC-SHA1 of
+// the body using the shared secret. An empty secret never verifies.
+func VerifySignature(body []byte, signature, secret string) bool {
+	if secret == "" || signature == "" {
+		return false
+	}
+	given, err := base64.StdEncoding.DecodeString(signature)
+	if err != nil {
+		return false
+	}
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write(body)
+	return hmac.Equal(given, mac.Sum(nil))
+}
+
+// ReplayGuard rejects deliveries that are too old, or whose ID has already
+// been applied within the tolerance window. The tolerance has to cover the
+// sender's retries. A delivery is only remembered once it is applied, so a
+// retry of one that failed is accepted.
+type ReplayGuard struct {
+	Tolerance time.Duration
+
+	mutex    sync.Mutex
+	seen     map[string]time.Time
+	applying map[string]bool
+}
+
+// NewReplayGuard creates a guard accepting events created up to tolerance
+// before now.
+func NewReplayGuard(tolerance time.Duration) *ReplayGuard {
+	return &ReplayGuard{
+		Tolerance: tolerance,
+		seen:      map[string]time.Time{},
+		applying:  map[string]bool{},
+	}
+}
+
+// ErrReplayed is returned by Check for a delivery ID that was already applied.
+var ErrReplayed = errors.New("webhook event already delivered")
+
+// ErrInProgress is returned by Check for a delivery ID that is still being
+// applied.
+var ErrInProgress = errors.New("webhook event is being applied")
+
+// clockSkew is how far ahead of this server's clock an event may have been
+// created.
+const clockSkew = 5 * time.Minute
+
+// Check returns an error if the delivery should be rejected. Otherwise the
+// delivery is claimed until Done is called with whether it was applied. An ID
+// already applied is reported as ErrReplayed however old the event is, so the
+// redelivery can be acknowledged.
+func (guard *ReplayGuard) Check(id string, createdAt time.Time) error {
+	now := time.Now()
+
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	// Forget deliveries old enough to be rejected by timestamp anyway.
+	for seenID, seenAt := range guard.seen {
+		if now.Sub(seenAt) > 2*guard.Tolerance {
+			delete(guard.seen, seenID)
+		}
+	}
+	if _, ok := guard.seen[id]; ok {
+		return ErrReplayed
+	}
+	if guard.applying[id] {
+		return ErrInProgress
+	}
+
+	if createdAt.Before(now.Add(-guard.Tolerance)) || createdAt.After(now.Add(clockSkew)) {
+		return fmt.Errorf("webhook event created at %s is outside the accepted window",
+			createdAt.Format(time.RFC3339))
+	}
+	guard.applying[id] = true
+	return nil
+}
+
+// Done releases a delivery claimed by Check. An applied delivery is remembered
+// and later ones with its ID are reported as replayed, otherwise it may be
+// delivered again.
+func (guard *ReplayGuard) Done(id string, applied bool) {
+	guard.mutex.Lock()
+	defer guard.mutex.Unlock()
+	delete(guard.applying, id)
+	if applied {
+		guard.seen[id] = time.Now()
+	}
+}
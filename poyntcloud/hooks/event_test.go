@@ -0,0 +1,93 @@
+package hooks
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+// sign returns the signature POYNT would send for the body.
+func sign(body, secret string) string {
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(body))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func TestVerifySignature(t *testing.T) {
+	body := `{"id":"1","eventType":"TRANSACTION_CAPTURED"}`
+	tests := []struct {
+		name      string
+		body      string
+		signature string
+		secret    string
+		want      bool
+	}{
+		{"valid", body, sign(body, "secret"), "secret", true},
+		{"wrong secret", body, sign(body, "other"), "secret", false},
+		{"changed body", body + " ", sign(body, "secret"), "secret", false},
+		{"empty secret", body, sign(body, ""), "", false},
+		{"empty signature", body, "", "secret", false},
+		{"not base64", body, "not base64!", "secret", false},
+	}
+
+	for _, test := range tests {
+		if got := VerifySignature([]byte(test.body), test.signature, test.secret); got != test.want {
+			t.Errorf("%s: VerifySignature = %t, want %t", test.name, got, test.want)
+		}
+	}
+}
+
+func TestReplayGuard(t *testing.T) {
+	now := time.Now()
+	tests := []struct {
+		name      string
+		createdAt time.Time
+		wantErr   bool
+	}{
+		{"now", now, false},
+		{"within tolerance", now.Add(-4 * time.Minute), false},
+		{"too old", now.Add(-6 * time.Minute), true},
+		{"clock skew", now.Add(4 * time.Minute), false},
+		{"in the future", now.Add(6 * time.Minute), true},
+	}
+
+	for _, test := range tests {
+		guard := NewReplayGuard(5 * time.Minute)
+		if err := guard.Check("event", test.createdAt); (err != nil) != test.wantErr {
+			t.Errorf("%s: Check error = %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+}
+
+func TestReplayGuardDelivery(t *testing.T) {
+	guard := NewReplayGuard(5 * time.Minute)
+	now := time.Now()
+
+	if err := guard.Check("event", now); err != nil {
+		t.Fatalf("first delivery: unexpected error: %s", err)
+	}
+	if err := guard.Check("event", now); err != ErrInProgress {
+		t.Errorf("delivery while applying: error = %v, want %v", err, ErrInProgress)
+	}
+
+	// A delivery that failed to apply may be retried.
+	guard.Done("event", false)
+	if err := guard.Check("event", now); err != nil {
+		t.Fatalf("retry after failure: unexpected error: %s", err)
+	}
+
+	guard.Done("event", true)
+	if err := guard.Check("event", now); err != ErrReplayed {
+		t.Errorf("delivery after applying: error = %v, want %v", err, ErrReplayed)
+	}
+	if err := guard.Check("other", now); err != nil {
+		t.Errorf("other delivery: unexpected error: %s", err)
+	}
+
+	// A redelivery of an applied event is acknowledged however old it is.
+	if err := guard.Check("event", now.Add(-time.Hour)); err != ErrReplayed {
+		t.Errorf("old delivery after applying: error = %v, want %v", err, ErrReplayed)
+	}
+}
@@ -0,0 +1,184 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+)
+
+// Event types a hook can subscribe to.
+const (
+	TransactionAuthorized = "TRANSACTION_AUTHORIZED"
+	TransactionCaptured   = "TRANSACTION_CAPTURED"
+	TransactionVoided     = "TRANSACTION_VOIDED"
+	TransactionRefunded   = "TRANSACTION_REFUNDED"
+	TransactionUpdated    = "TRANSACTION_UPDATED"
+	ApplicationSubscribed = "APPLICATION_SUBSCRIPTION_START"
+	ApplicationCancelled  = "APPLICATION_SUBSCRIPTION_END"
+)
+
+// Hook is a webhook subscription. POYNT posts an event to DeliveryURL whenever
+// one of EventTypes happens for the business.
+type Hook struct {
+	ID            string    `json:"id,omitempty"`
+	ApplicationID string    `json:"applicationId"`
+	BusinessID    string    `json:"businessId"`
+	DeliveryURL   string    `json:"deliveryUrl"`
+	EventTypes    []string  `json:"eventTypes"`
+	Secret        string    `json:"secret,omitempty"`
+	CreatedAt     time.Time `json:"createdAt,omitempty"`
+	UpdatedAt     time.Time `json:"updatedAt,omitempty"`
+}
+
+// hookList is a page of hooks.
+type hookList struct {
+	Hooks []Hook `json:"hooks"`
+}
+
+// List returns the hooks our application has registered for the business.
+func List(ctx context.Context, c *client.Client) ([]Hook, error) {
+	query := url.Values{}
+	query.Add("businessId", c.Config.BusinessID)
+
+	list := hookList{}
+	if err := c.Do(ctx, "GET", "/hooks", query, nil, &list); err != nil {
+		return nil, fmt.Errorf("error listing hooks: %s", err)
+	}
+
+	var hooks []Hook
+	for _, hook := range list.Hooks {
+		if hook.ApplicationID == c.Config.ApplicationID {
+			hooks = append(hooks, hook)
+		}
+	}
+	return hooks, nil
+}
+
+// Register subscribes the given URL to the event types for the business.
+func Register(ctx context.Context, c *client.Client, deliveryURL string, eventTypes []string) (*Hook, error) {
+	hook := Hook{
+		ApplicationID: c.Config.ApplicationID,
+		BusinessID:    c.Config.BusinessID,
+		DeliveryURL:   deliveryURL,
+		EventTypes:    eventTypes,
+		Secret:        c.Config.WebhookSecret,
+	}
+
+	created := Hook{}
+	if err := c.Do(ctx, "POST", "/hooks", nil, &hook, &created); err != nil {
+		return nil, fmt.Errorf("error registering hook for %s: %s", deliveryURL, err)
+	}
+	return &created, nil
+}
+
+// Update replaces the delivery URL and event types of an existing hook.
+func Update(ctx context.Context, c *client.Client, hook *Hook) (*Hook, error) {
+	patch := []client.PatchOperation{
+		client.AddOperation("/deliveryUrl", hook.DeliveryURL),
+		client.AddOperation("/eventTypes", hook.EventTypes),
+	}
+	if c.Config.WebhookSecret != "" {
+		patch = append(patch, client.AddOperation("/secret", c.Config.WebhookSecret))
+	}
+
+	updated := Hook{}
+	if err := c.Do(ctx, "PATCH", "/hooks/"+url.PathEscape(hook.ID), nil, patch, &updated); err != nil {
+		return nil, fmt.Errorf("error updating hook %s: %s", hook.ID, err)
+	}
+	return &updated, nil
+}
+
+// Delete removes the hook with the given ID.
+func Delete(ctx context.Context, c *client.Client, id string) error {
+	if err := c.Do(ctx, "DELETE", "/hooks/"+url.PathEscape(id), nil, nil, nil); err != nil {
+		return fmt.Errorf("error deleting hook %s: %s", id, err)
+	}
+	return nil
+}
+
+// Change is an action Reconcile took, or would take, on a hook.
+type Change struct {
+	Action string // create, update or delete
+	Hook   Hook
+}
+
+func (change Change) String() string {
+	return fmt.Sprintf("%s %s %s", change.Action, change.Hook.DeliveryURL,
+		strings.Join(change.Hook.EventTypes, ","))
+}
+
+// Reconcile makes the registered hooks match the desired list, matching hooks
+// by delivery URL. Hooks not in the list are deleted. With dryRun set the
+// changes are returned without being made.
+func Reconcile(ctx context.Context, c *client.Client, desired []config.Hook, dryRun bool) ([]Change, error) {
+	existing, err := List(ctx, c)
+	if err != nil {
+		return nil, err
+	}
+	byURL := map[string]Hook{}
+	for _, hook := range existing {
+		byURL[hook.DeliveryURL] = hook
+	}
+
+	var changes []Change
+	wanted := map[string]bool{}
+	for _, want := range desired {
+		wanted[want.DeliveryURL] = true
+		hook, ok := byURL[want.DeliveryURL]
+		switch {
+		case !ok:
+			hook = Hook{DeliveryURL: want.DeliveryURL, EventTypes: want.EventTypes}
+			changes = append(changes, Change{"create", hook})
+		case !sameEventTypes(hook.EventTypes, want.EventTypes):
+			hook.EventTypes = want.EventTypes
+			changes = append(changes, Change{"update", hook})
+		}
+	}
+	for _, hook := range existing {
+		if !wanted[hook.DeliveryURL] {
+			changes = append(changes, Change{"delete", hook})
+		}
+	}
+
+	if dryRun {
+		return changes, nil
+	}
+	for _, change := range changes {
+		switch change.Action {
+		case "create":
+			_, err = Register(ctx, c, change.Hook.DeliveryURL, change.Hook.EventTypes)
+		case "update":
+			_, err = Update(ctx, c, &change.Hook)
+		case "delete":
+			err = Delete(ctx, c, change.Hook.ID)
+		}
+		if err != nil {
+			return changes, err
+		}
+	}
+	return changes, nil
+}
+
+// sameEventTypes reports whether both lists hold the same event types in any
+// order.
+func sameEventTypes(a, b []string) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]string(nil), a...)
+	b = append([]string(nil), b...)
+	sort.Strings(a)
+	sort.Strings(b)
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,181 @@
+package money
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Money is an exact amount in the minor units of an ISO 4217 currency, e.g.
+// 1999 NZD is $19.99 and 1999 JPY is ¥1999.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// ErrCurrencyMismatch is returned when combining amounts in different
+// currencies.
+var ErrCurrencyMismatch = errors.New("money: currencies do not match")
+
+// New creates an amount of minor units in the given currency.
+func New(amount int64, currency string) Money {
+	return Money{Amount: amount, Currency: strings.ToUpper(currency)}
+}
+
+// Parse converts a decimal string such as "19.99" into an exact amount of the
+// currency, without going through a float. More decimal places than the
+// currency has are an error, unless they are zeros.
+func Parse(amount, currency string) (Money, error) {
+	currency = strings.ToUpper(currency)
+	exponent, ok := exponents[currency]
+	if !ok {
+		return Money{}, fmt.Errorf("money: unknown currency %q", currency)
+	}
+
+	s := strings.TrimSpace(amount)
+	negative := strings.HasPrefix(s, "-")
+	s = strings.TrimPrefix(s, "-")
+	whole, fraction := s, ""
+	if i := strings.Index(s, "."); i >= 0 {
+		whole, fraction = s[:i], s[i+1:]
+	}
+	if whole == "" && fraction == "" || !digits(whole) || !digits(fraction) {
+		return Money{}, fmt.Errorf("money: invalid amount %q", amount)
+	}
+
+	fraction = strings.TrimRight(fraction, "0")
+	if len(fraction) > exponent {
+		return Money{}, fmt.Errorf("money: %q has more than %d decimal places for %s",
+			amount, exponent, currency)
+	}
+	fraction += strings.Repeat("0", exponent-len(fraction))
+
+	minor, err := strconv.ParseInt(whole+fraction, 10, 64)
+	if err != nil && whole+fraction != "" {
+		return Money{}, fmt.Errorf("money: invalid amount %q", amount)
+	}
+	if negative {
+		minor = -minor
+	}
+	return Money{Amount: minor, Currency: currency}, nil
+}
+
+// digits reports whether s is made only of the digits 0-9.
+func digits(s string) bool {
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// Valid reports whether the code is a known ISO 4217 currency.
+func Valid(currency string) bool {
+	_, ok := exponents[currency]
+	return ok
+}
+
+// Exponent returns the number of decimal places of the currency, 2 if unknown.
+func Exponent(currency string) int {
+	if exponent, ok := exponents[strings.ToUpper(currency)]; ok {
+		return exponent
+	}
+	return 2
+}
+
+// Decimal formats the amount as a decimal string without the currency, e.g.
+// "19.99". This is the inverse of Parse.
+func (m Money) Decimal() string {
+	amount := m.Amount
+	sign := ""
+	if amount < 0 {
+		sign = "-"
+		amount = -amount
+	}
+	exponent := Exponent(m.Currency)
+	s := strconv.FormatInt(amount, 10)
+	if exponent == 0 {
+		return sign + s
+	}
+	if len(s) <= exponent {
+		s = strings.Repeat("0", exponent-len(s)+1) + s
+	}
+	return sign + s[:len(s)-exponent] + "." + s[len(s)-exponent:]
+}
+
+// String formats the amount with its currency, e.g. "19.99 NZD".
+func (m Money) String() string {
+	return m.Decimal() + " " + m.Currency
+}
+
+// IsZero reports whether the amount is zero.
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+// IsPositive reports whether the amount is greater than zero.
+func (m Money) IsPositive() bool {
+	return m.Amount > 0
+}
+
+// Add returns the sum of both amounts.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount + other.Amount, Currency: m.Currency}, nil
+}
+
+// Sub returns the amount less other.
+func (m Money) Sub(other Money) (Money, error) {
+	if m.Currency != other.Currency {
+		return Money{}, ErrCurrencyMismatch
+	}
+	return Money{Amount: m.Amount - other.Amount, Currency: m.Currency}, nil
+}
+
+// Times returns the amount multiplied by n, e.g. a unit price by a quantity.
+func (m Money) Times(n int64) Money {
+	return Money{Amount: m.Amount * n, Currency: m.Currency}
+}
+
+// Cmp compares both amounts, returning -1, 0 or 1.
+func (m Money) Cmp(other Money) (int, error) {
+	if m.Currency != other.Currency {
+		return 0, ErrCurrencyMismatch
+	}
+	switch {
+	case m.Amount < other.Amount:
+		return -1, nil
+	case m.Amount > other.Amount:
+		return 1, nil
+	}
+	return 0, nil
+}
+
+// exponents is the number of minor unit decimal places of each ISO 4217
+// currency.
+var exponents = map[string]int{
+	"AED": 2, "AFN": 2, "ALL": 2, "AMD": 2, "ANG": 2, "AOA": 2, "ARS": 2, "AUD": 2,
+	"AWG": 2, "AZN": 2, "BAM": 2, "BBD": 2, "BDT": 2, "BGN": 2, "BHD": 3, "BIF": 0,
+	"BMD": 2, "BND": 2, "BOB": 2, "BRL": 2, "BSD": 2, "BTN": 2, "BWP": 2, "BYN": 2,
+	"BZD": 2, "CAD": 2, "CDF": 2, "CHF": 2, "CLP": 0, "CNY": 2, "COP": 2, "CRC": 2,
+	"CUP": 2, "CVE": 2, "CZK": 2, "DJF": 0, "DKK": 2, "DOP": 2, "DZD": 2, "EGP": 2,
+	"ERN": 2, "ETB": 2, "EUR": 2, "FJD": 2, "FKP": 2, "GBP": 2, "GEL": 2, "GHS": 2,
+	"GIP": 2, "GMD": 2, "GNF": 0, "GTQ": 2, "GYD": 2, "HKD": 2, "HNL": 2, "HTG": 2,
+	"HUF": 2, "IDR": 2, "ILS": 2, "INR": 2, "IQD": 3, "IRR": 2, "ISK": 0, "JMD": 2,
+	"JOD": 3, "JPY": 0, "KES": 2, "KGS": 2, "KHR": 2, "KMF": 0, "KPW": 2, "KRW": 0,
+	"KWD": 3, "KYD": 2, "KZT": 2, "LAK": 2, "LBP": 2, "LKR": 2, "LRD": 2, "LSL": 2,
+	"LYD": 3, "MAD": 2, "MDL": 2, "MGA": 2, "MKD": 2, "MMK": 2, "MNT": 2, "MOP": 2,
+	"MRU": 2, "MUR": 2, "MVR": 2, "MWK": 2, "MXN": 2, "MYR": 2, "MZN": 2, "NAD": 2,
+	"NGN": 2, "NIO": 2, "NOK": 2, "NPR": 2, "NZD": 2, "OMR": 3, "PAB": 2, "PEN": 2,
+	"PGK": 2, "PHP": 2, "PKR": 2, "PLN": 2, "PYG": 0, "QAR": 2, "RON": 2, "RSD": 2,
+	"RUB": 2, "RWF": 0, "SAR": 2, "SBD": 2, "SCR": 2, "SDG": 2, "SEK": 2, "SGD": 2,
+	"SHP": 2, "SLE": 2, "SOS": 2, "SRD": 2, "SSP": 2, "STN": 2, "SYP": 2, "SZL": 2,
+	"THB": 2, "TJS": 2, "TMT": 2, "TND": 3, "TOP": 2, "TRY": 2, "TTD": 2, "TWD": 2,
+	"TZS": 2, "UAH": 2, "UGX": 0, "USD": 2, "UYU": 2, "UZS": 2, "VES": 2, "VND": 0,
+	"VUV": 0, "WST": 2, "XAF": 0, "XCD": 2, "XOF": 0, "XPF": 0, "YER": 2, "ZAR": 2,
+	"ZMW": 2, "ZWL": 2,
+}
@@ -0,0 +1,154 @@
+package money
+
+import "testing"
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		amount   string
+		currency string
+		want     int64
+		wantErr  bool
+	}{
+		{"19.99", "NZD", 1999, false},
+		{"19.99", "nzd", 1999, false},
+		{"0.29", "USD", 29, false},
+		{"1.1", "AUD", 110, false},
+		{"1.", "AUD", 100, false},
+		{".5", "AUD", 50, false},
+		{"1.500", "AUD", 150, false}, // Trailing zeros are not extra places
+		{" 7 ", "NZD", 700, false},
+		{"-19.99", "NZD", -1999, false},
+		{"1999", "JPY", 1999, false},
+		{"1999.00", "JPY", 1999, false},
+		{"1999.5", "JPY", 0, true},
+		{"1.234", "KWD", 1234, false},
+		{"1.2345", "KWD", 0, true},
+		{"0.001", "KWD", 1, false},
+		{"1.999", "NZD", 0, true},
+		{"92233720368547758.07", "USD", 9223372036854775807, false},
+		{"92233720368547758.08", "USD", 0, true}, // Overflows int64
+		{"99999999999999999999", "JPY", 0, true},
+		{"", "NZD", 0, true},
+		{".", "NZD", 0, true},
+		{"-", "NZD", 0, true},
+		{"1,000.00", "NZD", 0, true},
+		{"1e3", "NZD", 0, true},
+		{"+5", "NZD", 0, true},
+		{"--5", "NZD", 0, true},
+		{"5", "XYZ", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := Parse(test.amount, test.currency)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("Parse(%q, %s) = %v, want an error", test.amount, test.currency, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("Parse(%q, %s): unexpected error: %s", test.amount, test.currency, err)
+			continue
+		}
+		if got.Amount != test.want {
+			t.Errorf("Parse(%q, %s) = %d, want %d", test.amount, test.currency, got.Amount, test.want)
+		}
+	}
+}
+
+func TestDecimal(t *testing.T) {
+	tests := []struct {
+		money Money
+		want  string
+	}{
+		{New(1999, "NZD"), "19.99"},
+		{New(5, "NZD"), "0.05"},
+		{New(0, "NZD"), "0.00"},
+		{New(-1999, "NZD"), "-19.99"},
+		{New(-5, "USD"), "-0.05"},
+		{New(1999, "JPY"), "1999"},
+		{New(1234, "KWD"), "1.234"},
+		{New(1, "KWD"), "0.001"},
+	}
+
+	for _, test := range tests {
+		if got := test.money.Decimal(); got != test.want {
+			t.Errorf("%d %s Decimal() = %q, want %q", test.money.Amount, test.money.Currency, got, test.want)
+		}
+	}
+}
+
+func TestParseDecimalRoundTrip(t *testing.T) {
+	tests := []Money{
+		New(1999, "NZD"),
+		New(1, "USD"),
+		New(-250, "AUD"),
+		New(0, "EUR"),
+		New(1999, "JPY"),
+		New(1234, "KWD"),
+		New(9223372036854775807, "USD"),
+	}
+
+	for _, want := range tests {
+		got, err := Parse(want.Decimal(), want.Currency)
+		if err != nil {
+			t.Errorf("Parse(%q, %s): unexpected error: %s", want.Decimal(), want.Currency, err)
+			continue
+		}
+		if got != want {
+			t.Errorf("Parse(%q, %s) = %v, want %v", want.Decimal(), want.Currency, got, want)
+		}
+	}
+}
+
+func TestArithmetic(t *testing.T) {
+	a, b := New(1999, "NZD"), New(1, "NZD")
+
+	if sum, err := a.Add(b); err != nil || sum != New(2000, "NZD") {
+		t.Errorf("Add = %v, %v, want 20.00 NZD", sum, err)
+	}
+	if difference, err := a.Sub(b); err != nil || difference != New(1998, "NZD") {
+		t.Errorf("Sub = %v, %v, want 19.98 NZD", difference, err)
+	}
+	if product := a.Times(3); product != New(5997, "NZD") {
+		t.Errorf("Times = %v, want 59.97 NZD", product)
+	}
+	if cmp, err := a.Cmp(b); err != nil || cmp != 1 {
+		t.Errorf("Cmp = %d, %v, want 1", cmp, err)
+	}
+	if cmp, err := b.Cmp(a); err != nil || cmp != -1 {
+		t.Errorf("Cmp = %d, %v, want -1", cmp, err)
+	}
+	if cmp, err := a.Cmp(a); err != nil || cmp != 0 {
+		t.Errorf("Cmp = %d, %v, want 0", cmp, err)
+	}
+
+	usd := New(1, "USD")
+	if _, err := a.Add(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Add across currencies: error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := a.Sub(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Sub across currencies: error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+	if _, err := a.Cmp(usd); err != ErrCurrencyMismatch {
+		t.Errorf("Cmp across currencies: error = %v, want %v", err, ErrCurrencyMismatch)
+	}
+}
+
+func TestExponent(t *testing.T) {
+	tests := []struct {
+		currency string
+		want     int
+	}{
+		{"NZD", 2},
+		{"jpy", 0},
+		{"KWD", 3},
+		{"XYZ", 2},
+	}
+
+	for _, test := range tests {
+		if got := Exponent(test.currency); got != test.want {
+			t.Errorf("Exponent(%s) = %d, want %d", test.currency, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,126 @@
+package reports
+
+import (
+	"context"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/url"
+	"strconv"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+)
+
+// Batch is a settlement batch: the transactions a store closed out and sent
+// for settlement together.
+type Batch struct {
+	ID         string    `json:"id"`
+	BusinessID string    `json:"businessId"`
+	StoreID    string    `json:"storeId"`
+	DeviceID   string    `json:"deviceId,omitempty"`
+	Status     string    `json:"status"` // OPEN, CLOSED, SETTLED
+	Currency   string    `json:"currency"`
+	OpenedAt   time.Time `json:"openedAt"`
+	ClosedAt   time.Time `json:"closedAt,omitempty"`
+	SettledAt  time.Time `json:"settledAt,omitempty"`
+	Totals     Totals    `json:"totals"`
+}
+
+// Totals of a batch, with amounts in minor units of the batch currency.
+type Totals struct {
+	SaleCount      int   `json:"saleCount"`
+	SaleAmount     int64 `json:"saleAmount"`
+	RefundCount    int   `json:"refundCount"`
+	RefundAmount   int64 `json:"refundAmount"`
+	TipAmount      int64 `json:"tipAmount"`
+	CashbackAmount int64 `json:"cashbackAmount"`
+	FeeAmount      int64 `json:"feeAmount"`
+	// NetAmount is what the batch settles for: sales less refunds and fees.
+	NetAmount int64 `json:"netAmount"`
+}
+
+// batchList is a page of batches.
+type batchList struct {
+	Batches []Batch `json:"batches"`
+}
+
+// Number of batches requested per page when listing.
+const pageSize = 100
+
+// ListBatches returns the settlement batches of a store opened between from and
+// to.
+func ListBatches(ctx context.Context, c *client.Client, storeID string, from, to time.Time) ([]Batch, error) {
+	var batches []Batch
+	for offset := 0; ; offset += pageSize {
+		query := url.Values{}
+		query.Add("storeId", storeID)
+		query.Add("startAt", from.UTC().Format(time.RFC3339))
+		query.Add("endAt", to.UTC().Format(time.RFC3339))
+		query.Add("startOffset", strconv.Itoa(offset))
+		query.Add("limit", strconv.Itoa(pageSize))
+
+		page := batchList{}
+		if err := c.Do(ctx, "GET", c.BusinessPath("settlements"), query, nil, &page); err != nil {
+			return nil, fmt.Errorf("error listing settlement batches: %s", err)
+		}
+		for _, batch := range page.Batches {
+			// Fill in fields POYNT leaves out on older batches.
+			if batch.StoreID == "" {
+				batch.StoreID = storeID
+			}
+			if batch.BusinessID == "" {
+				batch.BusinessID = c.Config.BusinessID
+			}
+			t := batch.Totals
+			batch.Totals.NetAmount = t.SaleAmount - t.RefundAmount - t.FeeAmount
+			batches = append(batches, batch)
+		}
+		if len(page.Batches) < pageSize {
+			return batches, nil
+		}
+	}
+}
+
+// WriteJSON writes the batches as an indented JSON array.
+func WriteJSON(w io.Writer, batches []Batch) error {
+	if batches == nil {
+		batches = []Batch{}
+	}
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "\t")
+	return encoder.Encode(batches)
+}
+
+// WriteCSV writes the batches as CSV with a header row, one row per batch.
+func WriteCSV(w io.Writer, batches []Batch) error {
+	writer := csv.NewWriter(w)
+	writer.Write([]string{
+		"batch_id", "store_id", "device_id", "status", "currency",
+		"opened_at", "closed_at", "settled_at",
+		"sale_count", "sale_amount", "refund_count", "refund_amount",
+		"tip_amount", "cashback_amount", "fee_amount", "net_amount",
+	})
+	for _, batch := range batches {
+		t := batch.Totals
+		writer.Write([]string{
+			batch.ID, batch.StoreID, batch.DeviceID, batch.Status, batch.Currency,
+			formatTime(batch.OpenedAt), formatTime(batch.ClosedAt), formatTime(batch.SettledAt),
+			strconv.Itoa(t.SaleCount), strconv.FormatInt(t.SaleAmount, 10),
+			strconv.Itoa(t.RefundCount), strconv.FormatInt(t.RefundAmount, 10),
+			strconv.FormatInt(t.TipAmount, 10), strconv.FormatInt(t.CashbackAmount, 10),
+			strconv.FormatInt(t.FeeAmount, 10), strconv.FormatInt(t.NetAmount, 10),
+		})
+	}
+	writer.Flush()
+	return writer.Error()
+}
+
+// formatTime formats a time for CSV, leaving unset times empty.
+func formatTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.Format(time.RFC3339)
+}
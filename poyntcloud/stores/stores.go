@@ -0,0 +1,47 @@
+package stores
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Store is a location of the business.
+type Store struct {
+	ID          string `json:"id"`
+	DisplayName string `json:"displayName"`
+	Currency    string `json:"currency"`
+	Timezone    string `json:"timezone,omitempty"`
+	Status      string `json:"status,omitempty"`
+}
+
+// Get returns the store with the given ID.
+func Get(ctx context.Context, c *client.Client, id string) (*Store, error) {
+	store := Store{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("stores", id), nil, nil, &store); err != nil {
+		return nil, fmt.Errorf("error getting store %s: %s", id, err)
+	}
+	return &store, nil
+}
+
+// Currency returns the currency payments in the configured store are taken in:
+// the configured currency if there is one, otherwise the store's currency in
+// POYNT. Either way it must be a valid ISO 4217 code.
+func Currency(ctx context.Context, c *client.Client) (string, error) {
+	currency := c.Config.Currency
+	if currency == "" {
+		store, err := Get(ctx, c, c.Config.StoreID)
+		if err != nil {
+			return "", err
+		}
+		currency = store.Currency
+	}
+	currency = strings.ToUpper(currency)
+	if !money.Valid(currency) {
+		return "", fmt.Errorf("store currency %q is not a valid ISO 4217 code", currency)
+	}
+	return currency, nil
+}
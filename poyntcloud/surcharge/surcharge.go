@@ -0,0 +1,128 @@
+package surcharge
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Ways a surcharge is applied.
+const (
+	// ModeOff takes no surcharge.
+	ModeOff = ""
+	// ModeAdd adds the surcharge to the purchase amount before sending it.
+	ModeAdd = "add"
+	// ModePrompt shows the surcharge on the terminal for the customer to accept
+	// before it is added.
+	ModePrompt = "prompt"
+)
+
+// Card types rates can be set for besides card brands.
+const (
+	CardDebit  = "DEBIT"
+	CardCredit = "CREDIT"
+)
+
+// regionCaps are the most a merchant may surcharge in each region, in basis
+// points. Zero means surcharging is not allowed.
+var regionCaps = map[string]int{
+	"US": 300, // Card brand rules, debit excluded.
+	"CA": 240,
+	"GB": 0,
+	"EU": 0,
+	"NZ": 10000,
+	"AU": 10000, // Limited to the cost of acceptance, set Cap to it.
+}
+
+// regionsWithoutDebit do not allow debit cards to be surcharged.
+var regionsWithoutDebit = map[string]bool{
+	"US": true,
+}
+
+// Surcharge is the fee added to a payment for the card used.
+type Surcharge struct {
+	Amount      money.Money `json:"amount"`
+	BasisPoints int         `json:"basisPoints"`
+}
+
+// Rate returns the rate for the card in basis points, capped by the region's
+// law and the configured cap. The card type is a brand such as AMEX, or empty
+// if unknown. Funding is DEBIT or CREDIT as the payment policy forces it, or
+// empty if the customer may still choose. Brand rates win over debit/credit
+// rates, which win over the flat rate. While the funding is unknown the lowest
+// of these applies, as a card of any brand may turn out to be debit.
+func Rate(rules config.Surcharge, cardType, funding string) (int, error) {
+	switch rules.Mode {
+	case ModeOff:
+		return 0, nil
+	case ModeAdd, ModePrompt:
+	default:
+		return 0, fmt.Errorf("unknown surcharge mode %q", rules.Mode)
+	}
+
+	region := strings.ToUpper(rules.Region)
+	regionCap, ok := regionCaps[region]
+	if !ok {
+		return 0, fmt.Errorf("unknown surcharge region %q", rules.Region)
+	}
+	// Where debit may not be surcharged, only a card known to be credit is.
+	if regionsWithoutDebit[region] && funding != CardCredit {
+		return 0, nil
+	}
+
+	rate := rules.BasisPoints
+	debitRate, credit := rate, rate
+	if r, ok := rules.CardTypes[CardDebit]; ok {
+		debitRate = r
+	}
+	if r, ok := rules.CardTypes[CardCredit]; ok {
+		credit = r
+	}
+	switch funding {
+	case CardDebit:
+		rate = debitRate
+	case CardCredit:
+		rate = credit
+	default:
+		// The customer may still pay by debit, so never charge more than that.
+		rate = debitRate
+		if credit < rate {
+			rate = credit
+		}
+	}
+	if brandRate, ok := rules.CardTypes[strings.ToUpper(cardType)]; ok && cardType != "" {
+		if funding != "" || brandRate < rate {
+			rate = brandRate
+		}
+	}
+
+	if rate > regionCap {
+		rate = regionCap
+	}
+	if rules.Cap > 0 && rate > rules.Cap {
+		rate = rules.Cap
+	}
+	if rate < 0 {
+		return 0, fmt.Errorf("invalid surcharge rate %d", rate)
+	}
+	return rate, nil
+}
+
+// Compute returns the surcharge on the amount for the card, rounded half up to
+// the currency's minor unit.
+func Compute(amount money.Money, rules config.Surcharge, cardType, funding string) (Surcharge, error) {
+	rate, err := Rate(rules, cardType, funding)
+	if err != nil {
+		return Surcharge{}, err
+	}
+	fee := (amount.Amount*int64(rate) + 5000) / 10000
+	return Surcharge{Amount: money.New(fee, amount.Currency), BasisPoints: rate}, nil
+}
+
+// Percent formats the rate as a percentage, e.g. "1.5%".
+func (s Surcharge) Percent() string {
+	percent := fmt.Sprintf("%d.%02d", s.BasisPoints/100, s.BasisPoints%100)
+	return strings.TrimRight(strings.TrimRight(percent, "0"), ".") + "%"
+}
@@ -0,0 +1,109 @@
+package surcharge
+
+import (
+	"testing"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+func TestRate(t *testing.T) {
+	rates := map[string]int{CardDebit: 50, CardCredit: 200, "AMEX": 350}
+	tests := []struct {
+		name     string
+		rules    config.Surcharge
+		cardType string
+		funding  string
+		want     int
+		wantErr  bool
+	}{
+		{"off", config.Surcharge{BasisPoints: 150}, "", "", 0, false},
+		{"unknown mode", config.Surcharge{Mode: "sometimes", Region: "NZ"}, "", "", 0, true},
+		{"empty region", config.Surcharge{Mode: ModeAdd, BasisPoints: 150}, "", "", 0, true},
+		{"unknown region", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "XX"}, "", "", 0, true},
+		{"flat", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "NZ"}, "", "", 150, false},
+		{"region lower case", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "nz"}, "", "", 150, false},
+		{"debit", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "NZ"}, "", CardDebit, 50, false},
+		{"credit", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "NZ"}, "", CardCredit, 200, false},
+		{"funding unknown", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "NZ"}, "", "", 50, false},
+		{"brand", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "NZ"}, "amex", CardCredit, 350, false},
+		{"brand funding unknown", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "AU"}, "AMEX", "", 50, false},
+		{"lower brand funding unknown", config.Surcharge{Mode: ModeAdd, CardTypes: map[string]int{CardDebit: 100, CardCredit: 150, "EFTPOS": 20}, Region: "AU"}, "EFTPOS", "", 20, false},
+		{"regional cap", config.Surcharge{Mode: ModeAdd, CardTypes: rates, Region: "US"}, "AMEX", CardCredit, 300, false},
+		{"configured cap", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "NZ", Cap: 100}, "", "", 100, false},
+		{"not allowed", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "GB"}, "", CardCredit, 0, false},
+		{"US debit", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "US"}, "", CardDebit, 0, false},
+		{"US funding unknown", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "US"}, "VISA", "", 0, false},
+		{"US credit", config.Surcharge{Mode: ModeAdd, BasisPoints: 150, Region: "US"}, "", CardCredit, 150, false},
+		{"negative", config.Surcharge{Mode: ModePrompt, BasisPoints: -1, Region: "NZ"}, "", "", 0, true},
+	}
+
+	for _, test := range tests {
+		got, err := Rate(test.rules, test.cardType, test.funding)
+		if test.wantErr {
+			if err == nil {
+				t.Errorf("%s: Rate = %d, want an error", test.name, got)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("%s: Rate = %d, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestCompute(t *testing.T) {
+	tests := []struct {
+		amount      money.Money
+		basisPoints int
+		want        money.Money
+	}{
+		{money.New(10000, "NZD"), 150, money.New(150, "NZD")},
+		{money.New(1999, "NZD"), 150, money.New(30, "NZD")}, // 29.985 rounds up
+		{money.New(1966, "NZD"), 150, money.New(29, "NZD")}, // 29.49 rounds down
+		{money.New(100, "NZD"), 50, money.New(1, "NZD")},    // 0.5 rounds up
+		{money.New(1999, "JPY"), 250, money.New(50, "JPY")},
+		{money.New(1234, "KWD"), 100, money.New(12, "KWD")},
+		{money.New(10000, "NZD"), 0, money.New(0, "NZD")},
+	}
+
+	for _, test := range tests {
+		rules := config.Surcharge{Mode: ModeAdd, BasisPoints: test.basisPoints, Region: "NZ"}
+		got, err := Compute(test.amount, rules, "", CardCredit)
+		if err != nil {
+			t.Errorf("Compute(%v): unexpected error: %s", test.amount, err)
+			continue
+		}
+		if got.Amount != test.want || got.BasisPoints != test.basisPoints {
+			t.Errorf("Compute(%v) at %d = %v, want %v", test.amount, test.basisPoints, got, test.want)
+		}
+	}
+
+	if _, err := Compute(money.New(100, "USD"), config.Surcharge{Mode: ModeAdd, BasisPoints: 150}, "", CardCredit); err == nil {
+		t.Error("Compute without a region: want an error")
+	}
+}
+
+func TestPercent(t *testing.T) {
+	tests := []struct {
+		basisPoints int
+		want        string
+	}{
+		{150, "1.5%"},
+		{300, "3%"},
+		{125, "1.25%"},
+		{5, "0.05%"},
+		{0, "0%"},
+		{10000, "100%"},
+	}
+
+	for _, test := range tests {
+		if got := (Surcharge{BasisPoints: test.basisPoints}).Percent(); got != test.want {
+			t.Errorf("Percent of %d = %q, want %q", test.basisPoints, got, test.want)
+		}
+	}
+}
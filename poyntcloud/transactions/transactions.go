@@ -0,0 +1,194 @@
+package transactions
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Transaction statuses.
+const (
+	StatusAuthorized = "AUTHORIZED"
+	StatusCaptured   = "CAPTURED"
+	StatusVoided     = "VOIDED"
+	StatusRefunded   = "REFUNDED"
+	StatusDeclined   = "DECLINED"
+)
+
+// Transaction is a card transaction processed by a terminal.
+type Transaction struct {
+	ID                string             `json:"id"`
+	ParentID          string             `json:"parentId,omitempty"`
+	Action            string             `json:"action"` // AUTHORIZE, CAPTURE, SALE, REFUND, VOID
+	Status            string             `json:"status"`
+	Amounts           Amounts            `json:"amounts"`
+	ProcessorResponse *ProcessorResponse `json:"processorResponse,omitempty"`
+	References        []Reference        `json:"references,omitempty"`
+	CreatedAt         time.Time          `json:"createdAt,omitempty"`
+	UpdatedAt         time.Time          `json:"updatedAt,omitempty"`
+}
+
+// ProcessorResponse is the card issuer's answer to a transaction. A prepaid or
+// gift card may approve less than was asked for.
+type ProcessorResponse struct {
+	Status         string `json:"status"` // Successful, Failure
+	ApprovedAmount int64  `json:"approvedAmount"`
+}
+
+// Amounts of a transaction in minor units of the currency.
+type Amounts struct {
+	TransactionAmount int64  `json:"transactionAmount"`
+	OrderAmount       int64  `json:"orderAmount"`
+	TipAmount         int64  `json:"tipAmount,omitempty"`
+	CashbackAmount    int64  `json:"cashbackAmount,omitempty"`
+	Currency          string `json:"currency"`
+}
+
+// Reference links a transaction to an ID outside POYNT.
+type Reference struct {
+	ID         string `json:"id"`
+	Type       string `json:"type"` // POYNT_ORDER, CUSTOM
+	CustomType string `json:"customType,omitempty"`
+}
+
+// Amount returns the transaction amount.
+func (t *Transaction) Amount() money.Money {
+	return money.New(t.Amounts.TransactionAmount, t.Amounts.Currency)
+}
+
+// ReferenceID returns the reference ID the payment fragment was sent with, if
+// any.
+func (t *Transaction) ReferenceID() string {
+	for _, reference := range t.References {
+		if reference.Type == "CUSTOM" {
+			return reference.ID
+		}
+	}
+	return ""
+}
+
+// Get returns the transaction with the given ID.
+func Get(ctx context.Context, c *client.Client, id string) (*Transaction, error) {
+	transaction := Transaction{}
+	if err := c.Do(ctx, "GET", c.BusinessPath("transactions", id), nil, nil, &transaction); err != nil {
+		return nil, fmt.Errorf("error getting transaction %s: %s", id, err)
+	}
+	return &transaction, nil
+}
+
+// SendReceipt has POYNT send the receipt for a transaction to an email address
+// and/or phone number.
+func SendReceipt(ctx context.Context, c *client.Client, transactionID, email, phone string) error {
+	query := url.Values{}
+	if email != "" {
+		query.Add("email", email)
+	}
+	if phone != "" {
+		query.Add("phone", phone)
+	}
+	if len(query) == 0 {
+		return errors.New("an email address or phone number is required to send a receipt")
+	}
+
+	path := c.BusinessPath("transactions", transactionID, "receipt")
+	if err := c.Do(ctx, "POST", path, query, nil, nil); err != nil {
+		return fmt.Errorf("error sending receipt for transaction %s: %s", transactionID, err)
+	}
+	return nil
+}
+
+// Void cancels a transaction that has not been settled yet.
+func Void(ctx context.Context, c *client.Client, id string) (*Transaction, error) {
+	voided := Transaction{}
+	if err := c.Do(ctx, "POST", c.BusinessPath("transactions", id, "void"), nil, nil, &voided); err != nil {
+		return nil, fmt.Errorf("error voiding transaction %s: %s", id, err)
+	}
+	return &voided, nil
+}
+
+// Refund returns the given amount of a captured transaction to the card it was
+// paid with. No card needs to be present.
+func Refund(ctx context.Context, c *client.Client, id string, amount money.Money) (*Transaction, error) {
+	refund := Transaction{
+		ParentID: id,
+		Action:   "REFUND",
+		Amounts: Amounts{
+			TransactionAmount: amount.Amount,
+			OrderAmount:       amount.Amount,
+			Currency:          amount.Currency,
+		},
+	}
+
+	refunded := Transaction{}
+	if err := c.Do(ctx, "POST", c.BusinessPath("transactions"), nil, &refund, &refunded); err != nil {
+		return nil, fmt.Errorf("error refunding transaction %s: %s", id, err)
+	}
+	return &refunded, nil
+}
+
+// Increment raises an open authorization by the given amount, e.g. as a bar
+// tab grows past what was pre-authorized.
+func Increment(ctx context.Context, c *client.Client, id string, amount money.Money) (*Transaction, error) {
+	increment := Transaction{
+		Amounts: Amounts{
+			TransactionAmount: amount.Amount,
+			OrderAmount:       amount.Amount,
+			Currency:          amount.Currency,
+		},
+	}
+
+	updated := Transaction{}
+	if err := c.Do(ctx, "POST", c.BusinessPath("transactions", id, "increment"), nil, &increment, &updated); err != nil {
+		return nil, fmt.Errorf("error incrementing transaction %s: %s", id, err)
+	}
+	return &updated, nil
+}
+
+// Capture settles an authorization for the final amount plus a tip. The final
+// amount may be less than was authorized.
+func Capture(ctx context.Context, c *client.Client, id string, amount, tip money.Money) (*Transaction, error) {
+	if amount.Currency != tip.Currency {
+		return nil, money.ErrCurrencyMismatch
+	}
+	capture := Transaction{
+		Amounts: Amounts{
+			TransactionAmount: amount.Amount + tip.Amount,
+			OrderAmount:       amount.Amount,
+			TipAmount:         tip.Amount,
+			Currency:          amount.Currency,
+		},
+	}
+
+	captured := Transaction{}
+	if err := c.Do(ctx, "POST", c.BusinessPath("transactions", id, "capture"), nil, &capture, &captured); err != nil {
+		return nil, fmt.Errorf("error capturing transaction %s: %s", id, err)
+	}
+	return &captured, nil
+}
+
+// Reverse backs out a transaction: voided if it has not settled, otherwise
+// refunded in full.
+func Reverse(ctx context.Context, c *client.Client, id string) (*Transaction, error) {
+	transaction, err := Get(ctx, c, id)
+	if err != nil {
+		return nil, err
+	}
+	switch transaction.Status {
+	case StatusVoided, StatusRefunded, StatusDeclined:
+		return transaction, nil
+	case StatusAuthorized:
+		return Void(ctx, c, id)
+	}
+
+	voided, err := Void(ctx, c, id)
+	if err == nil {
+		return voided, nil
+	}
+	// Too late to void once settled, so refund instead.
+	return Refund(ctx, c, id, transaction.Amount())
+}
@@ -0,0 +1,92 @@
+package server
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/devices"
+)
+
+// A terminal not seen within this window is reported as offline.
+const deviceOnlineWindow = 15 * time.Minute
+
+// deviceStatus is a store device along with whether it is currently online.
+type deviceStatus struct {
+	devices.Device
+	Online bool `json:"online"`
+}
+
+// Devices lists the terminals registered to the configured store. Passing a
+// "name" query parameter resolves a single device by its friendly name.
+func (manager *Manager) Devices(w http.ResponseWriter, r *http.Request) {
+	storeDevices, err := devices.List(r.Context(), manager.Client, manager.Config.StoreID)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+
+	if name := r.URL.Query().Get("name"); name != "" {
+		device, err := devices.Resolve(storeDevices, name)
+		if err != nil {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": err.Error()})
+			return
+		}
+		writeJSON(w, http.StatusOK, deviceStatus{*device, device.Online(deviceOnlineWindow)})
+		return
+	}
+
+	statuses := make([]deviceStatus, 0, len(storeDevices))
+	for _, device := range storeDevices {
+		statuses = append(statuses, deviceStatus{device, device.Online(deviceOnlineWindow)})
+	}
+	writeJSON(w, http.StatusOK, statuses)
+}
+
+// broadcastResponse reports which terminals got a broadcast.
+type broadcastResponse struct {
+	Results []message.BroadcastResult `json:"results"`
+	Failed  int                       `json:"failed"`
+	Error   string                    `json:"error,omitempty"`
+}
+
+// Broadcast sends the posted JSON payload as a cloud message to every terminal
+// in the configured store, e.g. a config refresh or closing message. An optional
+// "concurrency" query parameter limits how many are sent at once.
+func (manager *Manager) Broadcast(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, broadcastResponse{Error: "POST a JSON payload"})
+		return
+	}
+	var payload json.RawMessage
+	if err := json.NewDecoder(io.LimitReader(r.Body, 1<<20)).Decode(&payload); err != nil {
+		writeJSON(w, http.StatusBadRequest, broadcastResponse{Error: "invalid JSON payload"})
+		return
+	}
+	concurrency, _ := strconv.Atoi(r.URL.Query().Get("concurrency"))
+
+	msg, err := message.NewMessage(manager.Config, payload)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, broadcastResponse{Error: err.Error()})
+		return
+	}
+	results, err := message.BroadcastToStore(r.Context(), manager.Client, msg, manager.Config.StoreID, concurrency)
+
+	res := broadcastResponse{Results: results}
+	status := http.StatusOK
+	if broadcastErr, ok := err.(*message.BroadcastError); ok {
+		// Some terminals got it, report which did not.
+		res.Failed = broadcastErr.Failed
+		res.Error = err.Error()
+		if broadcastErr.Failed == broadcastErr.Total {
+			status = http.StatusBadGateway
+		}
+	} else if err != nil {
+		res.Error = err.Error()
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, res)
+}
@@ -0,0 +1,153 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxPaymentWait is the longest GET /api/payments/{id} holds a request open,
+// short enough to get through proxies.
+const maxPaymentWait = 60 * time.Second
+
+// Comments are sent this often on an idle event stream so proxies keep it open.
+const eventKeepAlive = 15 * time.Second
+
+// paymentCreated is returned when a payment has been accepted for sending.
+type paymentCreated struct {
+	ID       string `json:"id"`
+	Status   string `json:"status"`
+	Location string `json:"location"`
+}
+
+// Payments creates a payment with POST /api/payments and returns its ID
+// straight away, while the payment is sent to the terminal in the background.
+// Takes the same parameters as Pay. Poll GET /api/payments/{id} for the result.
+func (manager *Manager) Payments(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	r.ParseForm()
+	req, err := manager.newPaymentRequest(r.Form)
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+
+	// The payment outlives the request, the payment timeout still applies.
+	go manager.sendPayment(context.Background(), req)
+
+	location := "/api/payments/" + req.ReferenceID
+	w.Header().Set("Location", location)
+	writeJSON(w, http.StatusAccepted, paymentCreated{
+		ID:       req.ReferenceID,
+		Status:   statusPending,
+		Location: location,
+	})
+}
+
+// PaymentByID returns the current state of a payment with
+// GET /api/payments/{id}. An optional "wait" of up to 60 seconds long-polls
+// until the payment is no longer pending.
+func (manager *Manager) PaymentByID(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "GET" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "GET required"})
+		return
+	}
+	id := strings.TrimPrefix(r.URL.Path, "/api/payments/")
+	if strings.HasSuffix(id, "/events") {
+		manager.streamPaymentEvents(w, r, strings.TrimSuffix(id, "/events"))
+		return
+	}
+
+	var wait time.Duration
+	if waitParam := r.URL.Query().Get("wait"); waitParam != "" {
+		seconds, err := strconv.Atoi(waitParam)
+		if err != nil || seconds < 0 {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid wait"})
+			return
+		}
+		wait = time.Duration(seconds) * time.Second
+		if wait > maxPaymentWait {
+			wait = maxPaymentWait
+		}
+	}
+
+	record, ok := waitPaymentRecord(r.Context(), id, wait)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown payment"})
+		return
+	}
+	writeJSON(w, http.StatusOK, recordResult(record))
+}
+
+// streamPaymentEvents streams a payment's state changes with Server-Sent
+// Events on GET /api/payments/{id}/events: message sent, delivered to the
+// terminal, card presented, authorized and finally the result. The stream
+// starts with the payment's current state and ends after the result.
+func (manager *Manager) streamPaymentEvents(w http.ResponseWriter, r *http.Request, id string) {
+	flusher, ok := w.(http.Flusher)
+	if !ok {
+		writeJSON(w, http.StatusInternalServerError, map[string]string{"error": "streaming not supported"})
+		return
+	}
+	// Subscribe before reading the record so no change is missed in between.
+	events, unsubscribe := paymentEvents.subscribe(id)
+	defer unsubscribe()
+
+	record, ok := getPaymentRecord(id)
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown payment"})
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.WriteHeader(http.StatusOK)
+
+	current := paymentEvent{ReferenceID: id, Stage: record.Status, At: record.UpdatedAt}
+	if !inFlight(record) {
+		res := recordResult(record)
+		current.Result = &res
+	}
+	writeEvent(w, current)
+	flusher.Flush()
+	if current.Result != nil {
+		return
+	}
+
+	keepAlive := time.NewTicker(eventKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		select {
+		case event := <-events:
+			writeEvent(w, event)
+			flusher.Flush()
+			if event.Result != nil && event.Stage != statusUnknown {
+				return
+			}
+		case <-keepAlive.C:
+			fmt.Fprint(w, ": keep-alive\n\n")
+			flusher.Flush()
+		case <-r.Context().Done():
+			return
+		}
+	}
+}
+
+// writeEvent writes a payment event in Server-Sent Events format.
+func writeEvent(w http.ResponseWriter, event paymentEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		log.Println("Error marshalling payment event:", err)
+		return
+	}
+	fmt.Fprintf(w, "data: %s\n\n", data)
+}
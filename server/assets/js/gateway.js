@@ -26,6 +26,34 @@ window.addEventListener("message", function(event) {
   if (data.payment.register_id) {
     regiserID = data.payment.register_id;
   }
+  // Store the currency of the sale so the server can check it matches the
+  // store's.
+  if (data.payment.currency) {
+    currency = data.payment.currency;
+  }
+  // Store a tip entered on the POS, used when the store takes fixed tips.
+  var tip = data.payment.tip || '';
+  // Store cash out asked for on a debit sale, if the store offers it.
+  var cashBack = data.payment.cash_back || '';
+  // Store the customer attached to the sale, if any.
+  var customer = {};
+  if (data.register_sale && data.register_sale.customer) {
+    customer = data.register_sale.customer;
+  }
+
+  // Payments towards the same Vend sale are tracked together, so the sale can
+  // be split across several cards.
+  if (data.register_sale && data.register_sale.id) {
+    saleID = data.register_sale.id;
+  } else if (typeof saleID === 'undefined') {
+    saleID = 'sale-' + Date.now();
+  }
+  saleTotal = amount;
+  // A return gives the sale it is for, so a refund can be checked against what
+  // was paid.
+  if (data.register_sale && data.register_sale.return_for) {
+    originalSaleID = data.register_sale.return_for;
+  }
 
   // If we get anything back from Vend other than the DATA step, something has
   // gone wrong.
@@ -33,46 +61,201 @@ window.addEventListener("message", function(event) {
     // TODO: Error handling.
     alert("Strange response from Vend.");
   } else {
-      // Instruct cashier to wait for customer input as payment is being sent to
-      // terminal.
-      $('#statusTextContainer').append("Tap or Insert Card");
-
-      // Request /pay endpoint to send amount to terminal and wait for respnse.
-      $.ajax({
-        type: "GET",
-        url: "pay",
-        data: { "amount": amount, "origin": getQueryString()['origin'] },
-      })
-      // If AJAX call is completed, then
-      .done(function(response) {
-        // TODO: check if response is not empty before parsing.
-        responseBody = JSON.parse(response);
-        // Always log repsonse body.
-        console.log(responseBody);
-        // Make sure status text is cleared.
-        $('#statusTextContainer').empty();
-        // Read transaction status and act appropriately.
-        checkTerminalResponse(responseBody);
-      })
-      // Likeliest reason for this will be communication. If the network is down
-      // or the like.
-      // TODO: Add fallback function.
-      .fail(function(error) {
-        // Always log error body.
-        console.log(error);
-        // Make sure status text is cleared.
-        $('#statusTextContainer').empty();
-        $('#statusTextContainer').append("Transaction Failed")
-        // Quit window, giving cashier chance to try again.
-        // TODO: Display retry button or transaction status check button.
-        window.setTimeout(exitStep, 2000)
-      })
-      .always(function() {
-        // TODO: Remove
-        // Nothing to do always.
-      })
+      payment = {
+        "currency": typeof currency !== 'undefined' ? currency : '',
+        "tip": tip,
+        "cash_back": cashBack,
+        "register_id": typeof regiserID !== 'undefined' ? regiserID : '',
+        "origin": getQueryString()['origin'],
+        // A Vend payment type set up for one card brand, e.g. with
+        // "?card_type=AMEX" on its gateway URL, gets that brand's surcharge.
+        "card_type": getQueryString()['card_type'] || '',
+        "customer_email": customer.email,
+        "customer_phone": customer.phone || customer.mobile,
+        "customer_first_name": customer.first_name,
+        "customer_last_name": customer.last_name,
+        "sale_id": saleID,
+        "sale_total": saleTotal,
+        "items": JSON.stringify(saleItems(data.register_sale)),
+      };
+      // Vend asks for refunds with a negative amount, which the customer is
+      // paid back on their card.
+      // A payment already under way when the page was reloaded is picked up
+      // again rather than sent twice.
+      var pendingID = sessionStorage.getItem('payment:' + saleID);
+      if (parseFloat(amount) < 0) {
+        sendRefund(amount);
+      } else if (pendingID) {
+        $('#statusTextContainer').append("Tap or Insert Card");
+        watchPayment(pendingID);
+        waitForPayment(pendingID);
+      } else {
+        sendPayment(amount);
+      }
   }}, false);
 
+// Send a card-present refund to the terminal and wait for the response.
+function sendRefund(refundAmount) {
+  $('#statusTextContainer').empty();
+  $('#statusTextContainer').append("Tap or Insert Card for Refund");
+
+  $.ajax({
+    type: "GET",
+    url: "refund",
+    data: {
+      "amount": refundAmount,
+      "currency": payment.currency,
+      "register_id": payment.register_id,
+      "origin": payment.origin,
+      "original_sale_id": typeof originalSaleID !== 'undefined' ? originalSaleID : '',
+      "reference_id": typeof originalSaleID !== 'undefined' ?
+        localStorage.getItem('reference:' + originalSaleID) || '' : '',
+    },
+  })
+  .done(function(response) {
+    responseBody = JSON.parse(response);
+    console.log(responseBody);
+    $('#statusTextContainer').empty();
+    checkTerminalResponse(responseBody);
+  })
+  .fail(function(error) {
+    console.log(error);
+    $('#statusTextContainer').empty();
+    $('#statusTextContainer').append("Refund Failed")
+    window.setTimeout(exitStep, 2000)
+  })
+}
+
+// Line items of the Vend sale, shown on the terminal and POYNT receipt. Vend
+// gives the price after discount and the discount and tax per unit, which are
+// sent for the whole line.
+function saleItems(sale) {
+  if (!sale || !sale.register_sale_products || typeof currency === 'undefined') {
+    return [];
+  }
+  var digits = currencyDigits(currency);
+  return $.map(sale.register_sale_products, function(product) {
+    var quantity = parseFloat(product.quantity) || 0;
+    var price = parseFloat(product.price) || 0;
+    var discount = parseFloat(product.discount) || 0;
+    var tax = product.tax_total !== undefined ?
+      parseFloat(product.tax_total) : (parseFloat(product.tax) || 0) * quantity;
+    return {
+      "name": product.name || product.product_name || product.product_id,
+      "sku": product.sku || '',
+      "quantity": quantity,
+      "unit_price": (price + discount).toFixed(digits),
+      "tax": tax.toFixed(digits),
+      "discount": (discount * quantity).toFixed(digits),
+    };
+  });
+}
+
+// Send a card payment for part or all of the sale to the terminal and wait for
+// the response.
+function sendPayment(tenderAmount) {
+  $('#splitContainer').hide();
+  $('#statusTextContainer').empty();
+  // Instruct cashier to wait for customer input as payment is being sent to
+  // terminal.
+  $('#statusTextContainer').append("Tap or Insert Card");
+
+  // Create the payment, which is sent to the terminal in the background, then
+  // wait for its result.
+  $.ajax({
+    type: "POST",
+    url: "api/payments",
+    data: $.extend({"amount": tenderAmount}, payment),
+  })
+  .done(function(created) {
+    console.log(created);
+    // Remember the payment so a reload picks up where it left off.
+    sessionStorage.setItem('payment:' + saleID, created.id);
+    watchPayment(created.id);
+    waitForPayment(created.id);
+  })
+  // The payment was refused before it reached the terminal.
+  .fail(function(error) {
+    console.log(error);
+    $('#statusTextContainer').empty();
+    checkTerminalResponse({
+      status: 'FAILED',
+      error: error.responseJSON ? error.responseJSON.error : '',
+    });
+  })
+}
+
+// Cashier facing text for each stage of a payment.
+var stageText = {
+  "SENT": "Sending to Terminal",
+  "DELIVERED": "Tap or Insert Card",
+  "CARD_PRESENTED": "Card Read, Processing",
+  "AUTHORIZED": "Authorizing",
+};
+
+// Show the payment's progress as the server streams it. The result itself is
+// still taken from waitForPayment.
+function watchPayment(paymentID) {
+  if (typeof EventSource === 'undefined') {
+    return;
+  }
+  var source = new EventSource("api/payments/" + paymentID + "/events");
+  source.onmessage = function(message) {
+    var event = JSON.parse(message.data);
+    console.log(event);
+    if (event.result) {
+      source.close();
+      return;
+    }
+    if (stageText[event.stage]) {
+      $('#statusTextContainer').empty();
+      $('#statusTextContainer').append(stageText[event.stage]);
+    }
+  };
+  // The browser reconnects on its own, give up only once the stream is closed.
+  source.onerror = function() {
+    if (source.readyState == EventSource.CLOSED) {
+      source.close();
+    }
+  };
+}
+
+// Long-poll the payment until the terminal has a result.
+function waitForPayment(paymentID) {
+  $.ajax({
+    type: "GET",
+    url: "api/payments/" + paymentID,
+    data: {"wait": 25},
+  })
+  .done(function(responseBody) {
+    // Always log repsonse body.
+    console.log(responseBody);
+    if (responseBody.status == 'PENDING' || responseBody.status == 'AUTHORIZED') {
+      waitForPayment(paymentID);
+      return;
+    }
+    sessionStorage.removeItem('payment:' + saleID);
+    // Make sure status text is cleared.
+    $('#statusTextContainer').empty();
+    // Read transaction status and act appropriately.
+    checkTerminalResponse(responseBody);
+  })
+  // Likeliest reason for this will be communication. If the network is down
+  // or the like, keep trying as the payment carries on without us.
+  .fail(function(error) {
+    console.log(error);
+    if (error.status == 404) {
+      sessionStorage.removeItem('payment:' + saleID);
+      $('#statusTextContainer').empty();
+      checkTerminalResponse({status: 'FAILED'});
+      return;
+    }
+    $('#statusTextContainer').empty();
+    $('#statusTextContainer').append("Connection Lost, Retrying")
+    window.setTimeout(function() { waitForPayment(paymentID) }, 3000)
+  })
+}
+
 // Check response from POYNT terminal.
 function checkTerminalResponse(responseBody) {
   // responseBody is the terminal response JSON, containing the request
@@ -88,19 +271,70 @@ function checkTerminalResponse(responseBody) {
     // TODO: AUTH step?
     case 'CANCELED':
       $('#statusTextContainer').append("Transaction Cancelled")
-      window.setTimeout(exitStep, 2500)
+      window.setTimeout(function() { exitOrSplit(responseBody.sale) }, 2500)
       break;
     case 'COMPLETED':
+      // Only accept once the whole sale is paid, otherwise take the rest on
+      // another card.
+      if (responseBody.sale && responseBody.sale.status != 'PAID') {
+        $('#statusTextContainer').append("Part Paid")
+        showSplit(responseBody.sale)
+        break;
+      }
       $('#statusTextContainer').append("Transaction Accepted")
-      window.setTimeout(acceptStep, 2500)
+      // Remember the payment so a later return of the sale refunds against it.
+      localStorage.setItem('reference:' + saleID, responseBody.referenceId);
+      // A card surcharge is added to the Vend sale so its total matches what
+      // the customer was charged.
+      var surcharge = null;
+      if (responseBody.surchargeAmount > 0) {
+        surcharge = {
+          amount: responseBody.surchargeAmount,
+          currency: responseBody.currency || payment.currency,
+        };
+        $('#statusTextContainer').append("<br>Includes " + formatMoney(surcharge) + " Card Surcharge")
+      }
+      // Cash out is handed over from the drawer, separately from the sale.
+      if (responseBody.cashbackAmount > 0) {
+        $('#statusTextContainer').append("<br>Give Customer " + formatMoney({
+          amount: responseBody.cashbackAmount,
+          currency: responseBody.currency || payment.currency,
+        }) + " Cash Out")
+        window.setTimeout(function() { acceptStep(surcharge) }, 5000)
+        break;
+      }
+      window.setTimeout(function() { acceptStep(surcharge) }, 2500)
+      break;
+    case 'PARTIALLY_APPROVED':
+      // A prepaid or gift card only covered part of the amount. This is never
+      // a completed sale, the rest has to be paid with another tender.
+      $('#statusTextContainer').append("Card Partially Approved")
+      if (responseBody.sale) {
+        window.setTimeout(function() { showSplit(responseBody.sale) }, 2500)
+      }
+      break;
+    case 'PENDING':
+      // Fallthrough, still waiting on the terminal when checked.
+    case 'UNKNOWN':
+      // The terminal did not respond in time, the payment may still go
+      // through. Let the cashier check again rather than wait forever.
+      $('#statusTextContainer').append("Payment Status Unknown, Check Terminal")
+      showUnknown(responseBody.referenceId)
+      break;
+    case 'EXPIRED':
+      // The terminal never picked up the payment, it may be offline.
+      $('#statusTextContainer').append("Terminal Not Responding")
+      window.setTimeout(function() { exitOrSplit(responseBody.sale) }, 2500)
       break;
     case 'FAILED':
       $('#statusTextContainer').append("Transaction Failed")
-      window.setTimeout(exitStep, 2500)
-      // Fallthrough
+      window.setTimeout(function() { exitOrSplit(responseBody.sale) }, 2500)
+      break;
     case 'REFUNDED':
-      // Fallthrough
-      // TODO: Have to build refund handling.
+      // A card-present refund was paid back to the customer.
+      $('#statusTextContainer').append("Refund Accepted")
+      window.setTimeout(acceptStep, 2500)
+      break;
     case 'VOIDED':
       // Fallthrough
       // TODO: What is being voided?
@@ -111,6 +345,98 @@ function checkTerminalResponse(responseBody) {
   }
 };
 
+// Offer to check on a payment left UNKNOWN, or to give up on it.
+function showUnknown(referenceID) {
+  unknownReferenceID = referenceID;
+  $('#unknownContainer').show();
+}
+
+// Ask the server whether a payment left UNKNOWN has since been settled by a
+// late callback or webhook.
+function checkPaymentStatus() {
+  $('#unknownContainer').hide();
+  $.ajax({
+    type: "GET",
+    url: "status",
+    data: {"reference_id": unknownReferenceID},
+  })
+  .done(function(responseBody) {
+    console.log(responseBody);
+    $('#statusTextContainer').empty();
+    checkTerminalResponse(responseBody);
+  })
+  .fail(function(error) {
+    console.log(error);
+    $('#unknownContainer').show();
+  })
+}
+
+// Exit unless part of the sale is already paid, in which case the cashier
+// either takes the rest on another card or cancels the sale.
+function exitOrSplit(sale) {
+  if (sale && sale.paid.amount > 0 && sale.status == 'OPEN') {
+    showSplit(sale);
+    return;
+  }
+  exitStep();
+}
+
+// Show what is left to pay, with the choice of paying it on another card or
+// abandoning the sale.
+function showSplit(sale) {
+  $('#statusTextContainer').empty();
+  $('#statusTextContainer').append(
+    "Paid " + formatMoney(sale.paid) + ", " + formatMoney(sale.remaining) + " remaining");
+  $('#tenderAmount').val(decimalMoney(sale.remaining));
+  $('#splitContainer').show();
+}
+
+// Pay the amount entered on another card.
+function nextTender() {
+  sendPayment($('#tenderAmount').val());
+}
+
+// Cancel a part paid sale, voiding or refunding the payments already made.
+function abandonSale() {
+  $('#splitContainer').hide();
+  $.ajax({
+    type: "POST",
+    url: "sales/abandon",
+    data: {"sale_id": saleID},
+  })
+  .done(function(response) {
+    console.log(response);
+    $('#statusTextContainer').empty();
+    $('#statusTextContainer').append("Sale Cancelled, Payments Reversed")
+    window.setTimeout(exitStep, 2500)
+  })
+  .fail(function(error) {
+    console.log(error);
+    $('#statusTextContainer').empty();
+    $('#statusTextContainer').append("Error Reversing Payments, Check Terminal")
+    $('#splitContainer').show();
+  })
+}
+
+// Number of decimal places of an ISO 4217 currency.
+function currencyDigits(currency) {
+  return new Intl.NumberFormat(undefined, {style: 'currency', currency: currency})
+    .resolvedOptions().maximumFractionDigits;
+}
+
+// Format an amount in minor units as a decimal string, e.g. "19.99".
+function decimalMoney(money) {
+  var digits = currencyDigits(money.currency);
+  return (money.amount / Math.pow(10, digits)).toFixed(digits);
+}
+
+// Format an amount in minor units for display, e.g. "$19.99".
+function formatMoney(money) {
+  var digits = currencyDigits(money.currency);
+  return new Intl.NumberFormat(undefined, {style: 'currency', currency: money.currency})
+    .format(money.amount / Math.pow(10, digits));
+}
+
 // Send payload to the Payments API.
  function sendObjectToVend(object) {
    var receiver = window.opener !== null ? window.opener : window.parent;
@@ -119,11 +445,16 @@ function checkTerminalResponse(responseBody) {
 
  // ACCEPT: Trigger a successful transaction. If the payment type supports
  // printing (and it’s enabled) an approved transaction receipt will also print.
- function acceptStep() {
-   sendObjectToVend({
+ function acceptStep(surcharge) {
+   var accept = {
      step: "ACCEPT",
      success: true,
-   });
+   };
+   // Vend adds a surcharge to the sale total, as a decimal amount.
+   if (surcharge) {
+     accept.surcharge = decimalMoney(surcharge);
+   }
+   sendObjectToVend(accept);
  };
 
 // DATA: Request additional information from Vend about the sale and payment.
@@ -1,43 +1,184 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"net/http"
-	"strconv"
+	"net/url"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/jtrotsky/go-poynt/poyntcloud"
 	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
 	"github.com/jtrotsky/go-poynt/poyntcloud/auth"
+	"github.com/jtrotsky/go-poynt/poyntcloud/client"
 	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/customers"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/stores"
+	"github.com/jtrotsky/go-poynt/poyntcloud/surcharge"
 )
 
 // TODO: Separate callback for OAuth callback as opposed to cloudMessage callback
 // The info returned from POYNT to specified callback URI.
 type callbackResult struct {
-	ReferenceID string `json:"referenceId"`
-	Status      string `json:"status"`
+	ReferenceID  string                `json:"referenceId"`
+	Status       string                `json:"status"`
+	Transactions []callbackTransaction `json:"transactions,omitempty"`
+	// Amounts approved on the terminal, in minor units of the currency. The tip
+	// is reported separately from the purchase amount.
+	Amount    int64  `json:"amount,omitempty"`
+	TipAmount int64  `json:"tipAmount,omitempty"`
+	Currency  string `json:"currency,omitempty"`
+	// Purchase amount sent to the terminal and how much of it the card
+	// approved. A prepaid or gift card may approve less, leaving the remaining
+	// amount to pay with another tender.
+	RequestedAmount int64 `json:"requestedAmount,omitempty"`
+	ApprovedAmount  int64 `json:"approvedAmount,omitempty"`
+	RemainingAmount int64 `json:"remainingAmount,omitempty"`
+	// Cash out approved on a debit sale, for the POS to hand over from the
+	// drawer. Not part of the purchase amount.
+	CashbackAmount int64 `json:"cashbackAmount,omitempty"`
+	// Card surcharge included in the amount, for the POS to add to the sale so
+	// its total matches.
+	SurchargeAmount int64 `json:"surchargeAmount,omitempty"`
+	// CardType is the card type the payment policy asked for, and Method how
+	// the customer actually paid, see fundingSource.
+	CardType string `json:"cardType,omitempty"`
+	Method   string `json:"method,omitempty"`
+	// Answer is the customer's input when the callback is for a prompt.
+	Answer string `json:"answer,omitempty"`
+	// Sale is the balance left when the payment is one of several paying for a
+	// sale.
+	Sale *saleSummary `json:"sale,omitempty"`
+	// Error explains why a payment failed before reaching the terminal.
+	Error string `json:"error,omitempty"`
 }
 
-var (
-	// String is the ID, and chan is the channel
-	callbacks = map[string]chan callbackResult{}
-	// Mutex handles locking maps, so can only be accessed by one CPU
-	callbackMutex = sync.Mutex{}
-)
+// callbackTransaction is a POYNT transaction made for the payment.
+type callbackTransaction struct {
+	ID                string             `json:"id"`
+	Status            string             `json:"status"`
+	Amounts           *callbackAmounts   `json:"amounts,omitempty"`
+	ProcessorResponse *processorResponse `json:"processorResponse,omitempty"`
+	FundingSource     *fundingSource     `json:"fundingSource,omitempty"`
+}
+
+// callbackAmounts are the amounts of a transaction in minor units.
+type callbackAmounts struct {
+	TransactionAmount int64 `json:"transactionAmount"`
+	OrderAmount       int64 `json:"orderAmount"`
+	TipAmount         int64 `json:"tipAmount"`
+	CashbackAmount    int64 `json:"cashbackAmount"`
+}
+
+// processorResponse is the card issuer's answer to a transaction.
+type processorResponse struct {
+	Status         string `json:"status"` // Successful, Failure
+	ApprovedAmount int64  `json:"approvedAmount"`
+}
+
+// approvedAmount returns how much of the purchase the issuer approved, leaving
+// out tip and cash back, and whether the transaction reported it.
+func (transaction *callbackTransaction) approvedAmount() (int64, bool) {
+	if transaction.ProcessorResponse == nil || transaction.ProcessorResponse.ApprovedAmount <= 0 {
+		return 0, false
+	}
+	approved := transaction.ProcessorResponse.ApprovedAmount
+	if transaction.Amounts != nil {
+		approved -= transaction.Amounts.TipAmount + transaction.Amounts.CashbackAmount
+	}
+	if approved < 0 {
+		approved = 0
+	}
+	return approved, true
+}
+
+// fundingSource is how the customer actually paid.
+type fundingSource struct {
+	Type  string `json:"type"` // CREDIT_DEBIT, CASH
+	Debit bool   `json:"debit"`
+	Card  struct {
+		Type string `json:"type"` // VISA, MASTERCARD
+	} `json:"card"`
+	EntryDetails struct {
+		EntryMode string `json:"entryMode"` // CONTACTLESS_INTEGRATED_CIRCUIT_CARD, KEYED
+	} `json:"entryDetails"`
+}
+
+// method describes the funding source, e.g. "DEBIT VISA CONTACTLESS_INTEGRATED_CIRCUIT_CARD".
+func (source *fundingSource) method() string {
+	if source.Type != "CREDIT_DEBIT" {
+		return source.Type
+	}
+	cardType := "CREDIT"
+	if source.Debit {
+		cardType = "DEBIT"
+	}
+	return strings.TrimSpace(cardType + " " + source.Card.Type + " " + source.EntryDetails.EntryMode)
+}
 
-// Manager stores credentials and configuration for a given store/user.
+// Manager stores configuration and the API client for a given store/user.
 type Manager struct {
-	Creds  *auth.OAuthCreds
 	Config *config.Configuration
+	// Client performs requests against the POYNT cloud API. It holds the
+	// credentials and refreshes them when they expire.
+	Client *client.Client
+	// Deliveries tracks whether terminals received the cloud messages sent.
+	Deliveries *message.Tracker
+
+	// currency payments in the store are taken in, as an ISO 4217 code, once
+	// known. See storeCurrency.
+	currency      string
+	currencyMutex sync.Mutex
 }
 
 // NewManager creates a manager that contains credentials and configuration for
 // a user.
 func NewManager(Auth *auth.OAuthCreds, Config *config.Configuration) *Manager {
-	return &Manager{Auth, Config}
+	return &Manager{
+		Config:     Config,
+		Client:     client.NewClient(Config, Auth),
+		Deliveries: message.NewTracker(),
+	}
+}
+
+// storeCurrency returns the currency payments in the store are taken in. It is
+// looked up on first use and again after a failed lookup, so a POYNT outage at
+// startup does not refuse payments until a restart.
+func (manager *Manager) storeCurrency() (string, error) {
+	manager.currencyMutex.Lock()
+	defer manager.currencyMutex.Unlock()
+	if manager.currency != "" {
+		return manager.currency, nil
+	}
+	currency, err := stores.Currency(context.Background(), manager.Client)
+	if err != nil {
+		return "", fmt.Errorf("error getting store currency: %s", err)
+	}
+	manager.currency = currency
+	return currency, nil
+}
+
+// paymentTimeout is how long to wait for the terminal to call back before the
+// payment's outcome is UNKNOWN.
+func (manager *Manager) paymentTimeout() time.Duration {
+	if manager.Config.PaymentTimeout > 0 {
+		return time.Duration(manager.Config.PaymentTimeout) * time.Second
+	}
+	return defaultPaymentTimeout
+}
+
+// messageTTL is how long a terminal has to pick up a cloud message.
+func (manager *Manager) messageTTL() time.Duration {
+	if manager.Config.MessageTTL > 0 {
+		return time.Duration(manager.Config.MessageTTL) * time.Second
+	}
+	return message.DefaultTTL
 }
 
 // Gateway is the basic landing page.
@@ -51,7 +192,6 @@ func (manager *Manager) Pay(w http.ResponseWriter, r *http.Request) {
 	// TODO: where to write header?
 	w.WriteHeader(http.StatusOK)
 
-	var err error
 	// Default amount to send.
 	var amountParam = "00.00"
 	r.ParseForm()
@@ -70,57 +210,252 @@ func (manager *Manager) Pay(w http.ResponseWriter, r *http.Request) {
 	// TODO: For debug
 	fmt.Println("Amount received:", amountParam)
 
-	// Convert amount string to float64 and check it's > 0.01.
-	paymentAmount, err := strconv.ParseFloat(amountParam, 64)
+	req, err := manager.newPaymentRequest(r.Form)
+	if err != nil {
+		failPayment(w, err.Error())
+		return
+	}
+	// Send amount to POYNT terminal and wait for it to call back.
+	res := manager.sendPayment(r.Context(), req)
+
+	// Turn response struct into JSON.
+	resJSON, err := json.MarshalIndent(res, "", "\t")
+	// Return to the AJAX call from the frontend.
+	w.Write(resJSON)
+}
+
+// paymentRequest is a payment checked and ready to send to the terminal.
+type paymentRequest struct {
+	ReferenceID string
+	SaleID      string
+	// CardType is the card brand of the Vend payment type, used for
+	// surcharging.
+	CardType string
+	// Customer sent by Vend, if any, saved in POYNT when the payment is sent.
+	Customer *customers.Customer
+	Payment  *message.Payment
+}
+
+// newPaymentRequest checks the payment parameters sent by the POS, builds the
+// payment fragment and starts a pending record for it.
+func (manager *Manager) newPaymentRequest(form url.Values) (*paymentRequest, error) {
+	// Refuse payments in a currency other than the store's.
+	storeCurrency, err := manager.storeCurrency()
+	if err != nil {
+		return nil, err
+	}
+	if currency := form.Get("currency"); currency != "" &&
+		!strings.EqualFold(currency, storeCurrency) {
+		return nil, fmt.Errorf("payment currency %s does not match store currency %s",
+			currency, storeCurrency)
+	}
+
+	// Convert amount string to an exact amount and check it's positive.
+	paymentAmount, err := money.Parse(form.Get("amount"), storeCurrency)
 	if err != nil {
 		fmt.Println("Error converting payment amount string to number:", err)
+		return nil, err
 	}
-	if paymentAmount < 0.01 {
-		// TODO: Fail elegantly.
-		// Return transaction failed, with reason why?
-		panic(err)
+	if !paymentAmount.IsPositive() {
+		return nil, errors.New("payment amount must be positive")
+	}
+
+	// A sale split across cards gives its total, and this payment is one part.
+	saleID := form.Get("sale_id")
+	saleTotal := paymentAmount
+	if totalParam := form.Get("sale_total"); saleID != "" && totalParam != "" {
+		if saleTotal, err = money.Parse(totalParam, storeCurrency); err != nil {
+			return nil, err
+		}
 	}
 
 	// Make call to poynt terminal.
 	// Generate UUID to identify transaction.
 	referenceID := poyntcloud.GenerateReferenceID()
-	// Channel expects a result
-	ch := make(chan callbackResult)
-
-	// Lock prevents reading from maps at same time.
-	callbackMutex.Lock()
-	// Create channel with our unique ID
-	callbacks[referenceID] = ch
-	callbackMutex.Unlock()
-
-	// Send amount to POYNT terminal.
-	// Check if cloud message sends successfully, if it doesn't then retry (most common
-	// cause being access token needing refresh).
-	if message.SendCloudMessage(manager.Config, manager.Creds, paymentAmount, referenceID); err != nil {
-		// TODO for debug
-		fmt.Println("Refreshing access token")
-		auth, err := auth.RefreshAccessToken(manager.Config, manager.Creds)
-		if err != nil {
-			fmt.Println("Error refreshing access token:", err)
-		}
-		// TODO for debug
-		fmt.Println("Sending cloud message again")
-		if message.SendCloudMessage(manager.Config, auth, paymentAmount, referenceID); err != nil {
-			log.Fatalf("Failed to send cloud message twice: %v", err)
-		}
-	}
-
-	// Wait until the channel gets a result from callback.
-	res := <-ch
-	// Turn response struct into JSON.
-	resJSON, err := json.MarshalIndent(res, "", "\t")
-	// Return to the AJAX call from the frontend.
+	newPaymentRecord(referenceID, paymentAmount)
+	fail := func(err error) (*paymentRequest, error) {
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.Status = statusFailed
+			record.Error = err.Error()
+		})
+		return nil, err
+	}
+	if saleID != "" {
+		if err = addSalePayment(saleID, saleTotal, referenceID, paymentAmount); err != nil {
+			return fail(err)
+		}
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.SaleID = saleID
+		})
+	}
+
+	payment := message.NewPayment(paymentAmount, referenceID)
+	err = payment.WithPolicy(manager.Config.PolicyFor(form.Get("register_id")))
+	if err == nil {
+		err = manager.addCashBack(payment, form.Get("cash_back"))
+	}
+	if err == nil {
+		err = manager.addTip(payment, form.Get("tip"))
+	}
+	if err != nil {
+		return fail(err)
+	}
+	// Items only add up when the payment covers the whole sale.
+	if paymentAmount == saleTotal {
+		manager.addItems(payment, form.Get("items"))
+	}
+
+	return &paymentRequest{
+		ReferenceID: referenceID,
+		SaleID:      saleID,
+		CardType:    form.Get("card_type"),
+		Customer:    newCustomer(form),
+		Payment:     payment,
+	}, nil
+}
+
+// sendPayment applies any card surcharge, sends the payment to the terminal
+// and waits for it to call back. The client refreshes the access token and
+// retries if it has expired. The result is also kept on the payment record.
+func (manager *Manager) sendPayment(ctx context.Context, req *paymentRequest) callbackResult {
+	referenceID, payment := req.ReferenceID, req.Payment
+	// Attach the Vend customer, if any, so loyalty and receipts follow them.
+	payment.CustomerID = manager.saveCustomer(ctx, req.Customer)
+	if err := manager.addSurcharge(ctx, payment, req.CardType); err != nil {
+		status := statusFailed
+		if err == errSurchargeDeclined {
+			status = statusCanceled
+		}
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.Status = status
+			record.Error = err.Error()
+		})
+		res := callbackResult{ReferenceID: referenceID, Status: status,
+			CardType: payment.CardType(), Error: err.Error()}
+		resolveCallback(res)
+		return res
+	}
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		record.CardType = payment.CardType()
+		record.CashBack = payment.CashBack()
+		record.Surcharge = payment.Surcharge()
+	})
+
+	res := manager.sendAndWait(ctx, referenceID, func() (string, error) {
+		return message.SendPayment(ctx, manager.Client, payment, manager.messageTTL())
+	})
+	if res.Error != "" {
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.Error = res.Error
+		})
+	}
+	res.CardType = payment.CardType()
+	if res.Status == statusCompleted || res.Status == statusPartial {
+		res.SurchargeAmount = payment.SurchargeAmount
+	}
+	if req.SaleID != "" {
+		res.Sale = manager.saleAfterPayment(req.SaleID)
+	}
+	return res
+}
+
+// addTip applies the store's tip mode to the payment. In fixed mode the tip is
+// the decimal amount passed from the POS.
+func (manager *Manager) addTip(payment *message.Payment, tipParam string) error {
+	tip := money.New(0, payment.CurrencyCode)
+	if manager.Config.TipMode == message.TipFixed && tipParam != "" {
+		var err error
+		if tip, err = money.Parse(tipParam, payment.CurrencyCode); err != nil {
+			return err
+		}
+	}
+	return payment.WithTip(manager.Config.TipMode, tip, manager.Config.TipPercentages)
+}
+
+// addCashBack adds the decimal cash out amount passed from the POS, if any,
+// within the store's cash back limits.
+func (manager *Manager) addCashBack(payment *message.Payment, cashBackParam string) error {
+	if cashBackParam == "" {
+		return nil
+	}
+	cashBack, err := money.Parse(cashBackParam, payment.CurrencyCode)
+	if err != nil {
+		return err
+	}
+	return payment.WithCashBack(cashBack, manager.Config.CashBack)
+}
+
+// errSurchargeDeclined is returned when the customer does not accept the card
+// surcharge on the terminal.
+var errSurchargeDeclined = errors.New("customer declined the card surcharge")
+
+// addSurcharge applies the store's surcharge rules to the payment. The card
+// type is a brand picked by the cashier, if any. In prompt mode the customer
+// has to accept the surcharge on the terminal first.
+func (manager *Manager) addSurcharge(ctx context.Context, payment *message.Payment, cardType string) error {
+	rules := manager.Config.Surcharge
+	// Until the card is presented it is only known to be debit or credit if the
+	// payment policy forces it.
+	funding := ""
+	switch payment.CardType() {
+	case message.CardDebit:
+		funding = surcharge.CardDebit
+	case message.CardCredit:
+		funding = surcharge.CardCredit
+	}
+	fee, err := surcharge.Compute(payment.Amount(), rules, cardType, funding)
+	if err != nil || fee.Amount.IsZero() {
+		return err
+	}
+
+	if rules.Mode == surcharge.ModePrompt {
+		question := fmt.Sprintf("A %s card surcharge of %s applies. Accept?",
+			fee.Percent(), fee.Amount)
+		referenceID := poyntcloud.GenerateReferenceID()
+		prompt := message.NewPrompt(message.PromptYesNo, question, referenceID)
+		res := manager.sendAndWait(ctx, referenceID, func() (string, error) {
+			return message.SendPrompt(ctx, manager.Client, prompt, manager.messageTTL())
+		})
+		if !strings.EqualFold(res.Answer, "yes") {
+			return errSurchargeDeclined
+		}
+	}
+	return payment.WithSurcharge(fee.Amount)
+}
+
+// failPayment returns a failed result to the AJAX call from the frontend for a
+// payment that was never sent to the terminal.
+func failPayment(w http.ResponseWriter, reason string) {
+	log.Println("Refusing payment:", reason)
+	resJSON, _ := json.MarshalIndent(callbackResult{Status: statusFailed, Error: reason}, "", "\t")
 	w.Write(resJSON)
+}
 
-	// Finally, remove the channel for memory's sake.
-	callbackMutex.Lock()
-	delete(callbacks, referenceID)
-	callbackMutex.Unlock()
+// newCustomer builds the customer from the details sent by Vend, or returns nil
+// if no customer was sent.
+func newCustomer(form url.Values) *customers.Customer {
+	email := form.Get("customer_email")
+	phone := form.Get("customer_phone")
+	if email == "" && phone == "" {
+		return nil
+	}
+	return customers.New(form.Get("customer_first_name"),
+		form.Get("customer_last_name"), email, phone)
+}
+
+// saveCustomer creates or updates the POYNT customer matching the customer sent
+// by Vend and returns its ID, or 0 if no customer was sent.
+func (manager *Manager) saveCustomer(ctx context.Context, customer *customers.Customer) int64 {
+	if customer == nil {
+		return 0
+	}
+	customer, err := customers.Save(ctx, manager.Client, customer)
+	if err != nil {
+		// A payment can go ahead without a customer.
+		log.Println("Error saving customer:", err)
+		return 0
+	}
+	return customer.ID
 }
 
 // Callback is a URL that listens for the POYNT terminals response messages.
@@ -133,23 +468,164 @@ func (manager *Manager) Callback(w http.ResponseWriter, r *http.Request) {
 		panic(err)
 	}
 
-	fmt.Printf("\nUSER ACTION: \n%s\n", messageResponse)
+	if manager.Config.Debug {
+		log.Printf("Terminal callback: %+v", messageResponse)
+	}
+
+	// Any call back means the terminal received the cloud message.
+	manager.Deliveries.Acknowledge(messageResponse.ReferenceID)
+	switch messageResponse.Status {
+	case statusReceived:
+		// The terminal app only acknowledged the message, the payment is still
+		// going.
+		publishStage(messageResponse.ReferenceID, eventDelivered)
+		return
+	case statusCardPresented:
+		publishStage(messageResponse.ReferenceID, eventCardPresented)
+		return
+	}
 
 	res := callbackResult{
 		// Status, reference.
-		ReferenceID: messageResponse.ReferenceID,
-		Status:      messageResponse.Status,
+		ReferenceID:  messageResponse.ReferenceID,
+		Status:       messageResponse.Status,
+		Transactions: messageResponse.Transactions,
+		Amount:       messageResponse.Amount,
+		TipAmount:    messageResponse.TipAmount,
+		Currency:     messageResponse.Currency,
+		Answer:       messageResponse.Answer,
+	}
+	for _, transaction := range res.Transactions {
+		if transaction.FundingSource != nil {
+			res.Method = transaction.FundingSource.method()
+		}
+		if transaction.Amounts != nil {
+			res.CashbackAmount += transaction.Amounts.CashbackAmount
+		}
 	}
+	manager.checkApproval(&res)
 
-	callbackMutex.Lock()
-	// Check callback[id] exists
-	ch, ok := callbacks[res.ReferenceID]
-	callbackMutex.Unlock()
-	if !ok {
+	updatePaymentRecord(res.ReferenceID, func(record *paymentRecord) {
+		record.Status = res.Status
+		markRefunded(record)
+		// The terminal settled it, even if we had stopped waiting.
+		record.Error = ""
+		if res.ApprovedAmount > 0 {
+			record.Approved = money.New(res.ApprovedAmount, record.Amount.Currency)
+		}
+		// Only what was approved is handed over, not what was asked for.
+		record.CashBack = money.New(res.CashbackAmount, record.Amount.Currency)
+		if res.TipAmount > 0 {
+			record.Tip = money.New(res.TipAmount, record.Amount.Currency)
+		}
+		if res.Method != "" {
+			record.Method = res.Method
+		}
+		for _, transaction := range res.Transactions {
+			if !record.hasTransaction(transaction.ID) {
+				record.TransactionIDs = append(record.TransactionIDs, transaction.ID)
+			}
+		}
+	})
+	manager.reverseIfAbandoned(res.ReferenceID)
+	ensureTab(res.ReferenceID)
+
+	// Pass the result to the waiting payment and any event streams.
+	if !resolveCallback(res) {
 		// Log and wonder what happend
 		// why did we never send that transaction
-		log.Printf("Error, couldn't find ID for chan: %v", err)
+		log.Printf("Error, no one waiting on payment: %s", res.ReferenceID)
+	}
+}
+
+// checkApproval compares the amount approved with the amount requested. A
+// payment the card only partly covered is reported as PARTIALLY_APPROVED with
+// the remaining amount, never as COMPLETED. A pre-auth stays AUTHORIZED, for
+// only the approved amount.
+func (manager *Manager) checkApproval(res *callbackResult) {
+	record, ok := getPaymentRecord(res.ReferenceID)
+	if !ok || record.Refund {
+		return
+	}
+	switch res.Status {
+	case statusCompleted, statusPartial, statusAuthorized:
+	default:
+		return
+	}
+	// The surcharge was sent as part of the purchase amount.
+	res.RequestedAmount = record.Amount.Amount + record.Surcharge.Amount
+
+	var approved int64
+	reported := false
+	for _, transaction := range res.Transactions {
+		if amount, ok := transaction.approvedAmount(); ok {
+			approved += amount
+			reported = true
+		}
+	}
+	if !reported {
+		approved = res.RequestedAmount
+		if res.Amount > 0 {
+			approved = res.Amount
+		}
+	}
+	res.ApprovedAmount = approved
+
+	if approved < res.RequestedAmount && res.Status != statusAuthorized {
+		res.Status = statusPartial
+		res.RemainingAmount = res.RequestedAmount - approved
 	}
-	// receive result on that channel
-	ch <- res
+}
+
+// PaymentStatus returns what is known of the payment given by "reference_id",
+// in the same form as Pay. Used to check on a payment left UNKNOWN.
+func (manager *Manager) PaymentStatus(w http.ResponseWriter, r *http.Request) {
+	record, ok := getPaymentRecord(r.URL.Query().Get("reference_id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown payment"})
+		return
+	}
+	writeJSON(w, http.StatusOK, recordResult(record))
+}
+
+// recordResult describes a payment record as a callback result.
+func recordResult(record paymentRecord) callbackResult {
+	res := callbackResult{
+		ReferenceID:     record.ReferenceID,
+		Status:          record.Status,
+		RequestedAmount: record.Amount.Amount + record.Surcharge.Amount,
+		ApprovedAmount:  record.Approved.Amount,
+		TipAmount:       record.Tip.Amount,
+		CashbackAmount:  record.CashBack.Amount,
+		SurchargeAmount: record.Surcharge.Amount,
+		Currency:        record.Amount.Currency,
+		CardType:        record.CardType,
+		Method:          record.Method,
+		Error:           record.Error,
+	}
+	if record.Status == statusPartial {
+		res.RemainingAmount = res.RequestedAmount - res.ApprovedAmount
+	}
+	for _, transactionID := range record.TransactionIDs {
+		res.Transactions = append(res.Transactions, callbackTransaction{ID: transactionID})
+	}
+	if record.SaleID != "" {
+		if summary, ok := getSaleSummary(record.SaleID); ok {
+			res.Sale = &summary
+		}
+	}
+	return res
+}
+
+// writeJSON writes the given value to the response as indented JSON.
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	resJSON, err := json.MarshalIndent(v, "", "\t")
+	if err != nil {
+		log.Println("Error marshalling response:", err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(resJSON)
 }
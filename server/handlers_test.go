@@ -0,0 +1,61 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestCheckApproval(t *testing.T) {
+	// approvedBy is a transaction the issuer approved the amount on, tip and
+	// cash back included.
+	approvedBy := func(amount, tip int64) []callbackTransaction {
+		return []callbackTransaction{{
+			ID:                "t1",
+			Amounts:           &callbackAmounts{TipAmount: tip},
+			ProcessorResponse: &processorResponse{Status: "Successful", ApprovedAmount: amount},
+		}}
+	}
+
+	tests := []struct {
+		name          string
+		refund        bool
+		res           callbackResult
+		wantStatus    string
+		wantApproved  int64
+		wantRemaining int64
+	}{
+		{"no amounts reported", false,
+			callbackResult{Status: statusCompleted}, statusCompleted, 1000, 0},
+		{"fully approved", false,
+			callbackResult{Status: statusCompleted, Transactions: approvedBy(1000, 0)}, statusCompleted, 1000, 0},
+		{"partly approved", false,
+			callbackResult{Status: statusCompleted, Transactions: approvedBy(600, 0)}, statusPartial, 600, 400},
+		{"approved with tip", false,
+			callbackResult{Status: statusCompleted, Transactions: approvedBy(1100, 100)}, statusCompleted, 1000, 0},
+		{"amount reported", false,
+			callbackResult{Status: statusCompleted, Amount: 700}, statusPartial, 700, 300},
+		{"pre-auth partly approved", false,
+			callbackResult{Status: statusAuthorized, Transactions: approvedBy(600, 0)}, statusAuthorized, 600, 0},
+		{"canceled", false,
+			callbackResult{Status: statusCanceled, Transactions: approvedBy(600, 0)}, statusCanceled, 0, 0},
+		{"refund", true,
+			callbackResult{Status: statusCompleted, Transactions: approvedBy(600, 0)}, statusCompleted, 0, 0},
+	}
+
+	manager := &Manager{}
+	for _, test := range tests {
+		resetState()
+		refund := test.refund
+		addRecord("payment", 1000, func(record *paymentRecord) {
+			record.Refund = refund
+		})
+		res := test.res
+		res.ReferenceID = "payment"
+		manager.checkApproval(&res)
+		if res.Status != test.wantStatus || res.ApprovedAmount != test.wantApproved ||
+			res.RemainingAmount != test.wantRemaining {
+			t.Errorf("%s: %s approved %d remaining %d, want %s approved %d remaining %d", test.name,
+				res.Status, res.ApprovedAmount, res.RemainingAmount,
+				test.wantStatus, test.wantApproved, test.wantRemaining)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package server
+
+import (
+	"sync"
+	"time"
+)
+
+// Stages a payment goes through before its result, streamed as events.
+const (
+	eventSent          = "SENT"           // Cloud message sent to the terminal.
+	eventDelivered     = "DELIVERED"      // The terminal received the message.
+	eventCardPresented = "CARD_PRESENTED" // The customer tapped, inserted or swiped a card.
+	eventAuthorized    = "AUTHORIZED"     // The card issuer authorized the payment.
+)
+
+// Events buffered per subscriber. A subscriber that falls further behind
+// misses events rather than holding up the others.
+const eventBuffer = 16
+
+// paymentEvent is a change in a payment's state. The last event of a payment
+// carries its result, and its stage is the result's status, e.g. COMPLETED or
+// DECLINED.
+type paymentEvent struct {
+	ReferenceID string          `json:"referenceId"`
+	Stage       string          `json:"stage"`
+	Result      *callbackResult `json:"result,omitempty"`
+	At          time.Time       `json:"at"`
+}
+
+// eventHub passes payment events to everyone subscribed to the payment: the
+// request waiting on its result and any event streams.
+type eventHub struct {
+	mutex       sync.Mutex
+	subscribers map[string]map[chan paymentEvent]bool
+}
+
+// paymentEvents is the hub every payment's events go through.
+var paymentEvents = newEventHub()
+
+// newEventHub creates a hub without subscribers.
+func newEventHub() *eventHub {
+	return &eventHub{subscribers: map[string]map[chan paymentEvent]bool{}}
+}
+
+// subscribe returns the events of the payment with the given reference ID and
+// a function to stop receiving them.
+func (hub *eventHub) subscribe(referenceID string) (<-chan paymentEvent, func()) {
+	ch := make(chan paymentEvent, eventBuffer)
+	hub.mutex.Lock()
+	if hub.subscribers[referenceID] == nil {
+		hub.subscribers[referenceID] = map[chan paymentEvent]bool{}
+	}
+	hub.subscribers[referenceID][ch] = true
+	hub.mutex.Unlock()
+
+	return ch, func() {
+		hub.mutex.Lock()
+		defer hub.mutex.Unlock()
+		delete(hub.subscribers[referenceID], ch)
+		if len(hub.subscribers[referenceID]) == 0 {
+			delete(hub.subscribers, referenceID)
+		}
+	}
+}
+
+// publish passes the event to the payment's subscribers and reports whether
+// there were any. It never blocks.
+func (hub *eventHub) publish(event paymentEvent) bool {
+	if event.At.IsZero() {
+		event.At = time.Now()
+	}
+	hub.mutex.Lock()
+	defer hub.mutex.Unlock()
+	for ch := range hub.subscribers[event.ReferenceID] {
+		select {
+		case ch <- event:
+		default:
+		}
+	}
+	return len(hub.subscribers[event.ReferenceID]) > 0
+}
+
+// publishStage tells subscribers the payment reached a stage.
+func publishStage(referenceID, stage string) {
+	paymentEvents.publish(paymentEvent{ReferenceID: referenceID, Stage: stage})
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"testing"
+)
+
+func TestEventHub(t *testing.T) {
+	hub := newEventHub()
+	first, unsubscribeFirst := hub.subscribe("payment")
+	second, unsubscribeSecond := hub.subscribe("payment")
+	other, unsubscribeOther := hub.subscribe("other")
+	defer unsubscribeOther()
+
+	if !hub.publish(paymentEvent{ReferenceID: "payment", Stage: eventSent}) {
+		t.Error("publish with subscribers = false, want true")
+	}
+	for name, events := range map[string]<-chan paymentEvent{"first": first, "second": second} {
+		select {
+		case event := <-events:
+			if event.Stage != eventSent || event.At.IsZero() {
+				t.Errorf("%s got %+v, want a timed %s event", name, event, eventSent)
+			}
+		default:
+			t.Errorf("%s got no event", name)
+		}
+	}
+	select {
+	case event := <-other:
+		t.Errorf("other payment got %+v", event)
+	default:
+	}
+
+	// A subscriber that is not reading misses events instead of blocking.
+	for i := 0; i < eventBuffer+1; i++ {
+		hub.publish(paymentEvent{ReferenceID: "payment", Stage: eventDelivered})
+	}
+	if len(first) != eventBuffer {
+		t.Errorf("buffered %d events, want %d", len(first), eventBuffer)
+	}
+
+	unsubscribeFirst()
+	unsubscribeSecond()
+	if hub.publish(paymentEvent{ReferenceID: "payment", Stage: eventAuthorized}) {
+		t.Error("publish without subscribers = true, want false")
+	}
+}
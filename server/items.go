@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// lineItemParam is a line of the Vend sale as sent by gateway.js. Tax and
+// discount are for the whole line.
+type lineItemParam struct {
+	Name      string      `json:"name"`
+	SKU       string      `json:"sku"`
+	Quantity  float64     `json:"quantity"`
+	UnitPrice json.Number `json:"unit_price"` // Decimal string, e.g. "19.99".
+	Tax       json.Number `json:"tax"`
+	Discount  json.Number `json:"discount"`
+}
+
+// lineItem converts the line into a payment fragment line item in the given
+// currency.
+func (param lineItemParam) lineItem(currency string) (message.LineItem, error) {
+	amounts := []json.Number{param.UnitPrice, param.Tax, param.Discount}
+	parsed := make([]int64, len(amounts))
+	for i, amount := range amounts {
+		if amount == "" {
+			continue
+		}
+		m, err := money.Parse(amount.String(), currency)
+		if err != nil {
+			return message.LineItem{}, fmt.Errorf("line item %q: %s", param.Name, err)
+		}
+		parsed[i] = m.Amount
+	}
+	return message.LineItem{
+		Name:      param.Name,
+		SKU:       param.SKU,
+		Quantity:  param.Quantity,
+		UnitPrice: parsed[0],
+		Tax:       parsed[1],
+		Discount:  parsed[2],
+	}, nil
+}
+
+// addItems adds the sale's line items, passed from the POS as a JSON array, to
+// the payment so they show on the terminal and receipt. Items that do not add
+// up to the purchase amount are left off rather than holding up the payment.
+func (manager *Manager) addItems(payment *message.Payment, itemsParam string) {
+	if itemsParam == "" {
+		return
+	}
+	var params []lineItemParam
+	if err := json.Unmarshal([]byte(itemsParam), &params); err != nil {
+		log.Println("Error reading line items:", err)
+		return
+	}
+
+	items := make([]message.LineItem, 0, len(params))
+	for _, param := range params {
+		item, err := param.lineItem(payment.CurrencyCode)
+		if err != nil {
+			log.Println("Error reading line items:", err)
+			return
+		}
+		items = append(items, item)
+	}
+	if err := payment.WithItems(items); err != nil {
+		log.Println("Sending payment without line items:", err)
+	}
+}
@@ -0,0 +1,276 @@
+package server
+
+import (
+	"context"
+	"log"
+	"sync"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Payment statuses, as reported by the terminal app.
+const (
+	statusPending    = "PENDING"
+	statusAuthorized = "AUTHORIZED"
+	statusCompleted  = "COMPLETED"
+	statusCanceled   = "CANCELED"
+	statusFailed     = "FAILED"
+	statusRefunded   = "REFUNDED"
+	statusVoided     = "VOIDED"
+	// A terminal app that acknowledges messages calls back with RECEIVED as
+	// soon as it gets the cloud message, before the customer has paid. See
+	// app_acknowledges in config.
+	statusReceived = "RECEIVED"
+	// The terminal app calls back with CARD_PRESENTED when the customer
+	// presents a card, before it is authorized.
+	statusCardPresented = "CARD_PRESENTED"
+	// POYNT dropped the cloud message before the terminal received it. Only
+	// known when the terminal app acknowledges messages.
+	statusExpired = "EXPIRED"
+	// The terminal did not call back in time, or the request waiting for it
+	// was cancelled. The payment may still complete, so check it again later.
+	statusUnknown = "UNKNOWN"
+	// The card, usually prepaid or a gift card, only covered part of the
+	// amount. The rest must be paid with another tender.
+	statusPartial = "PARTIALLY_APPROVED"
+)
+
+// paymentRecord is what we know about a payment sent to a terminal. It is
+// updated by both the terminal callback and POYNT webhooks.
+type paymentRecord struct {
+	ReferenceID    string      `json:"referenceId"`
+	SaleID         string      `json:"saleId,omitempty"` // Set when the sale is split across cards
+	Amount         money.Money `json:"amount"`           // Requested
+	Approved       money.Money `json:"approved"`         // Less than requested when partially approved
+	Tip            money.Money `json:"tip"`
+	CashBack       money.Money `json:"cashBack"`
+	Surcharge      money.Money `json:"surcharge"`          // Card fee, included in Approved but not in Amount
+	Refunded       money.Money `json:"refunded"`           // Refunded since, or being refunded
+	Refund         bool        `json:"refund,omitempty"`   // Money back to the customer, not a payment
+	RefundOf       string      `json:"refundOf,omitempty"` // Reference ID of the payment a refund is for
+	PreAuth        bool        `json:"preAuth,omitempty"`  // Opens a tab once authorized, see ensureTab
+	TabName        string      `json:"tabName,omitempty"`
+	CardType       string      `json:"cardType,omitempty"` // Asked for by the payment policy
+	Method         string      `json:"method,omitempty"`   // How the customer actually paid
+	Status         string      `json:"status"`
+	TransactionIDs []string    `json:"transactionIds,omitempty"`
+	Error          string      `json:"error,omitempty"` // Why the payment failed or is UNKNOWN
+	CreatedAt      time.Time   `json:"createdAt"`
+	UpdatedAt      time.Time   `json:"updatedAt"`
+}
+
+// hasTransaction reports whether the transaction belongs to the payment.
+func (record *paymentRecord) hasTransaction(id string) bool {
+	for _, transactionID := range record.TransactionIDs {
+		if transactionID == id {
+			return true
+		}
+	}
+	return false
+}
+
+var (
+	// Payment records by reference ID.
+	records      = map[string]*paymentRecord{}
+	recordsMutex = sync.Mutex{}
+	// recordsChanged is closed and replaced whenever a record is updated, to
+	// wake up anyone waiting on a payment.
+	recordsChanged = make(chan struct{})
+)
+
+// recordRetention is how long a payment is kept once it is no longer in
+// flight, for checking on it, refunding it and webhooks that come in late.
+const recordRetention = 7 * 24 * time.Hour
+
+// newPaymentRecord starts a pending record for a payment being sent. Payments
+// finished longer than recordRetention ago are forgotten.
+func newPaymentRecord(referenceID string, amount money.Money) {
+	recordsMutex.Lock()
+	defer recordsMutex.Unlock()
+	expireRecords(time.Now().Add(-recordRetention))
+	records[referenceID] = &paymentRecord{
+		ReferenceID: referenceID,
+		Amount:      amount,
+		Approved:    money.New(0, amount.Currency),
+		Tip:         money.New(0, amount.Currency),
+		CashBack:    money.New(0, amount.Currency),
+		Surcharge:   money.New(0, amount.Currency),
+		Refunded:    money.New(0, amount.Currency),
+		Status:      statusPending,
+		CreatedAt:   time.Now(),
+		UpdatedAt:   time.Now(),
+	}
+}
+
+// expireRecords removes the records of payments that have not been in flight
+// since before the given time. The recordsMutex must be held.
+func expireRecords(before time.Time) {
+	for referenceID, record := range records {
+		if !inFlight(*record) && record.UpdatedAt.Before(before) {
+			delete(records, referenceID)
+		}
+	}
+}
+
+// getPaymentRecord returns a copy of the record with the given reference ID.
+func getPaymentRecord(referenceID string) (paymentRecord, bool) {
+	recordsMutex.Lock()
+	defer recordsMutex.Unlock()
+	record, ok := records[referenceID]
+	if !ok {
+		return paymentRecord{}, false
+	}
+	return *record, true
+}
+
+// updatePaymentRecord applies update to the record with the given reference ID
+// and reports whether it was found.
+func updatePaymentRecord(referenceID string, update func(*paymentRecord)) bool {
+	recordsMutex.Lock()
+	defer recordsMutex.Unlock()
+	record, ok := records[referenceID]
+	if !ok {
+		return false
+	}
+	update(record)
+	record.UpdatedAt = time.Now()
+	close(recordsChanged)
+	recordsChanged = make(chan struct{})
+	return true
+}
+
+// waitPaymentRecord returns the record with the given reference ID once it is
+// no longer in flight, or as it is when the wait is up or ctx is cancelled.
+func waitPaymentRecord(ctx context.Context, referenceID string, wait time.Duration) (paymentRecord, bool) {
+	deadline := time.NewTimer(wait)
+	defer deadline.Stop()
+	for {
+		recordsMutex.Lock()
+		changed := recordsChanged
+		recordsMutex.Unlock()
+
+		record, ok := getPaymentRecord(referenceID)
+		if !ok || !inFlight(record) {
+			return record, ok
+		}
+		select {
+		case <-changed:
+		case <-deadline.C:
+			return record, true
+		case <-ctx.Done():
+			return record, true
+		}
+	}
+}
+
+// findPaymentByTransaction returns the reference ID of the payment a POYNT
+// transaction belongs to.
+func findPaymentByTransaction(transactionID string) (string, bool) {
+	recordsMutex.Lock()
+	defer recordsMutex.Unlock()
+	for referenceID, record := range records {
+		if record.hasTransaction(transactionID) {
+			return referenceID, true
+		}
+	}
+	return "", false
+}
+
+// resolveCallback passes the result to everyone subscribed to the payment and
+// reports whether anyone was. The request waiting on the payment takes the
+// first result, whether from callback, webhook or expiry.
+func resolveCallback(res callbackResult) bool {
+	return paymentEvents.publish(paymentEvent{
+		ReferenceID: res.ReferenceID,
+		Stage:       res.Status,
+		Result:      &res,
+	})
+}
+
+// defaultPaymentTimeout is how long to wait for the terminal to call back when
+// the store does not configure a timeout.
+const defaultPaymentTimeout = 3 * time.Minute
+
+// sendAndWait subscribes to the events of the given reference ID, sends the
+// cloud message and waits for the terminal to call back. It fails quickly if
+// the message could not be sent, or if the terminal app acknowledges messages
+// and the message expired before it did. Otherwise it gives up with UNKNOWN
+// once the payment timeout passes or ctx is cancelled.
+//
+// An app that only calls back with the result is never heard from while the
+// customer pays, so its messages are not failed on expiry: the terminal may
+// still take the payment.
+func (manager *Manager) sendAndWait(ctx context.Context, referenceID string, send func() (string, error)) callbackResult {
+	events, unsubscribe := paymentEvents.subscribe(referenceID)
+	defer unsubscribe()
+
+	messageID, err := send()
+	if err != nil {
+		log.Println("Failed to send cloud message:", err)
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.Status = statusFailed
+		})
+		res := callbackResult{ReferenceID: referenceID, Status: statusFailed, Error: err.Error()}
+		resolveCallback(res)
+		return res
+	}
+	manager.Deliveries.Sent(referenceID, messageID, manager.messageTTL())
+	defer manager.Deliveries.Forget(referenceID)
+	publishStage(referenceID, eventSent)
+
+	var expired <-chan struct{}
+	if manager.Config.AppAcknowledges {
+		expired = manager.Deliveries.Expired(referenceID)
+	}
+	timeout := time.NewTimer(manager.paymentTimeout())
+	defer timeout.Stop()
+
+	// Wait until an event carries the result, or fail quickly if POYNT dropped
+	// the message before the terminal picked it up.
+	for {
+		select {
+		case event := <-events:
+			if event.Result != nil {
+				return *event.Result
+			}
+		case <-expired:
+			log.Println("Cloud message expired before the terminal received it:", referenceID)
+			return stopWaiting(referenceID, events, statusExpired, "")
+		case <-timeout.C:
+			log.Println("Terminal did not call back in time:", referenceID)
+			return stopWaiting(referenceID, events, statusUnknown, "the terminal did not respond in time")
+		case <-ctx.Done():
+			log.Println("Request cancelled while waiting for the terminal:", referenceID)
+			return stopWaiting(referenceID, events, statusUnknown, "the request was cancelled")
+		}
+	}
+}
+
+// stopWaiting gives up on a callback with the given status. A payment left
+// UNKNOWN can still be settled by a late callback or webhook. If the result
+// arrived meanwhile, it is returned instead.
+func stopWaiting(referenceID string, events <-chan paymentEvent, status, reason string) callbackResult {
+	// Take a result that arrived while giving up.
+	for drained := false; !drained; {
+		select {
+		case event := <-events:
+			if event.Result != nil {
+				return *event.Result
+			}
+		default:
+			drained = true
+		}
+	}
+
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		if status == statusExpired {
+			record.Status = statusExpired
+		} else if record.Status == statusPending || record.Status == statusAuthorized {
+			record.Status = statusUnknown
+		}
+	})
+	res := callbackResult{ReferenceID: referenceID, Status: status, Error: reason}
+	resolveCallback(res)
+	return res
+}
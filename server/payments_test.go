@@ -0,0 +1,120 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/auth"
+	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// resetState forgets every payment, sale and tab, so tests don't see each
+// other's.
+func resetState() {
+	recordsMutex.Lock()
+	records = map[string]*paymentRecord{}
+	recordsMutex.Unlock()
+	salesMutex.Lock()
+	sales = map[string]*saleRecord{}
+	salesMutex.Unlock()
+	tabsMutex.Lock()
+	tabs = map[string]*tabRecord{}
+	tabsMutex.Unlock()
+}
+
+// fakePoynt serves the POYNT transaction endpoints the server uses, from the
+// transactions it is given by ID.
+type fakePoynt struct {
+	mutex  sync.Mutex
+	byID   map[string]transactions.Transaction
+	voided []string
+}
+
+func (poynt *fakePoynt) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	// /businesses/{businessId}/transactions/{transactionId}[/void]
+	parts := strings.Split(strings.Trim(r.URL.Path, "/"), "/")
+	if len(parts) < 4 || parts[2] != "transactions" {
+		http.NotFound(w, r)
+		return
+	}
+
+	poynt.mutex.Lock()
+	defer poynt.mutex.Unlock()
+	transaction, ok := poynt.byID[parts[3]]
+	if !ok {
+		http.NotFound(w, r)
+		return
+	}
+	if len(parts) == 5 && parts[4] == "void" && r.Method == "POST" {
+		transaction.Action = "VOID"
+		transaction.Status = transactions.StatusVoided
+		poynt.byID[parts[3]] = transaction
+		poynt.voided = append(poynt.voided, parts[3])
+	}
+	json.NewEncoder(w).Encode(transaction)
+}
+
+// newTestManager starts with no payments, sales or tabs and returns a manager
+// whose client talks to the fake POYNT, and a function to stop it.
+func newTestManager(poynt *fakePoynt) (*Manager, func()) {
+	resetState()
+	if poynt.byID == nil {
+		poynt.byID = map[string]transactions.Transaction{}
+	}
+	server := httptest.NewServer(poynt)
+	manager := NewManager(&auth.OAuthCreds{}, &config.Configuration{
+		BusinessID:      "business",
+		PoyntAPIHostURL: server.URL,
+	})
+	return manager, server.Close
+}
+
+// addRecord adds a payment record in the given state.
+func addRecord(referenceID string, amount int64, update func(*paymentRecord)) {
+	newPaymentRecord(referenceID, money.New(amount, "NZD"))
+	if update != nil {
+		updatePaymentRecord(referenceID, update)
+	}
+}
+
+func TestExpireRecords(t *testing.T) {
+	resetState()
+	addRecord("old-completed", 1000, func(record *paymentRecord) {
+		record.Status = statusCompleted
+	})
+	addRecord("old-unknown", 1000, func(record *paymentRecord) {
+		record.Status = statusUnknown
+	})
+	addRecord("new-completed", 1000, func(record *paymentRecord) {
+		record.Status = statusCompleted
+	})
+
+	recordsMutex.Lock()
+	old := time.Now().Add(-recordRetention - time.Hour)
+	records["old-completed"].UpdatedAt = old
+	records["old-unknown"].UpdatedAt = old
+	recordsMutex.Unlock()
+
+	addRecord("next", 1000, nil)
+	tests := []struct {
+		referenceID string
+		want        bool
+	}{
+		{"old-completed", false},
+		{"old-unknown", true}, // Still in flight
+		{"new-completed", true},
+		{"next", true},
+	}
+	for _, test := range tests {
+		if _, ok := getPaymentRecord(test.referenceID); ok != test.want {
+			t.Errorf("%s kept = %t, want %t", test.referenceID, ok, test.want)
+		}
+	}
+}
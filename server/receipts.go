@@ -0,0 +1,49 @@
+package server
+
+import (
+	"net/http"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// Receipts prints or sends the receipt for a payment. Called by the Vend iframe
+// after ACCEPT with the payment's "reference_id" (or a POYNT "transaction_id")
+// and either "print=true" or an "email" and/or "phone" to send it to.
+func (manager *Manager) Receipts(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	referenceID := r.Form.Get("reference_id")
+	transactionID := r.Form.Get("transaction_id")
+	if transactionID == "" {
+		record, ok := getPaymentRecord(referenceID)
+		if !ok || len(record.TransactionIDs) == 0 {
+			writeJSON(w, http.StatusNotFound, map[string]string{"error": "no transaction for payment"})
+			return
+		}
+		transactionID = record.TransactionIDs[len(record.TransactionIDs)-1]
+	}
+
+	email, phone := r.Form.Get("email"), r.Form.Get("phone")
+	if r.Form.Get("print") != "true" && email == "" && phone == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "print, email or phone is required"})
+		return
+	}
+
+	res := map[string]string{"transactionId": transactionID}
+	if r.Form.Get("print") == "true" {
+		messageID, err := message.PrintReceipt(r.Context(), manager.Client, message.NewReceipt(transactionID, referenceID))
+		if err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		res["messageId"] = messageID
+	}
+	if email != "" || phone != "" {
+		if err := transactions.SendReceipt(r.Context(), manager.Client, transactionID, email, phone); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+		res["sent"] = "true"
+	}
+	writeJSON(w, http.StatusOK, res)
+}
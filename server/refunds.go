@@ -0,0 +1,166 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud"
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Refund sends a card-present refund to the terminal, where the customer taps
+// or inserts the card to be paid back, and waits for a response. Called by the
+// Vend iframe with the "amount" to refund and optionally the original payment's
+// "reference_id", POYNT "transaction_id" or the "original_sale_id" it paid.
+func (manager *Manager) Refund(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+
+	storeCurrency, err := manager.storeCurrency()
+	if err != nil {
+		failPayment(w, err.Error())
+		return
+	}
+	if currency := r.Form.Get("currency"); currency != "" &&
+		!strings.EqualFold(currency, storeCurrency) {
+		failPayment(w, fmt.Sprintf("refund currency %s does not match store currency %s",
+			currency, storeCurrency))
+		return
+	}
+
+	// Vend sends refunds as negative amounts.
+	amount, err := money.Parse(strings.TrimPrefix(r.Form.Get("amount"), "-"), storeCurrency)
+	if err != nil {
+		failPayment(w, err.Error())
+		return
+	}
+	if !amount.IsPositive() {
+		failPayment(w, "refund amount must be positive")
+		return
+	}
+
+	// A refund against a payment we took may not be more than is left of it
+	// once earlier refunds are taken off. The amount is held against the
+	// payment while the refund is under way.
+	transactionID := r.Form.Get("transaction_id")
+	original, hasOriginal := originalPayment(r.Form, amount)
+	if hasOriginal {
+		if err = reserveRefund(original.ReferenceID, amount); err != nil {
+			failPayment(w, err.Error())
+			return
+		}
+		if transactionID == "" && len(original.TransactionIDs) > 0 {
+			transactionID = original.TransactionIDs[0]
+		}
+	}
+
+	referenceID := poyntcloud.GenerateReferenceID()
+	newPaymentRecord(referenceID, amount)
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		record.Refund = true
+		record.RefundOf = original.ReferenceID
+	})
+	refund := message.NewRefund(amount, referenceID, transactionID)
+	res := manager.sendAndWait(r.Context(), referenceID, func() (string, error) {
+		return message.SendPayment(r.Context(), manager.Client, refund, manager.messageTTL())
+	})
+
+	// The terminal reports a refund it completed as COMPLETED. The record is
+	// already marked if the callback came in while waiting.
+	if res.Status == statusCompleted {
+		res.Status = statusRefunded
+		updatePaymentRecord(referenceID, markRefunded)
+	}
+	// Only a refund that surely did not happen gives the amount back. One left
+	// UNKNOWN may still go through.
+	switch res.Status {
+	case statusFailed, statusCanceled, statusExpired:
+		if hasOriginal {
+			releaseRefund(original.ReferenceID, amount)
+		}
+	}
+	// Return to the AJAX call from the frontend, as Pay does.
+	resJSON, _ := json.MarshalIndent(res, "", "\t")
+	w.Write(resJSON)
+}
+
+// markRefunded marks a refund record the terminal completed as REFUNDED, so it
+// is never taken for a payment. The terminal reports refunds as COMPLETED, as
+// it does payments.
+func markRefunded(record *paymentRecord) {
+	if record.Refund && record.Status == statusCompleted {
+		record.Status = statusRefunded
+	}
+}
+
+// refundable is how much of a payment is left to refund.
+func refundable(record paymentRecord) money.Money {
+	left, err := paidAmount(record).Sub(record.Refunded)
+	if err != nil || left.Amount < 0 {
+		return money.New(0, record.Amount.Currency)
+	}
+	return left
+}
+
+// originalPayment finds the payment a refund is for, by its reference ID, one
+// of its POYNT transaction IDs or the Vend sale it paid for. Of a sale paid
+// with several cards, the first payment with enough left to refund is used.
+func originalPayment(form url.Values, amount money.Money) (paymentRecord, bool) {
+	var candidates []string
+	if referenceID := form.Get("reference_id"); referenceID != "" {
+		candidates = append(candidates, referenceID)
+	}
+	if transactionID := form.Get("transaction_id"); transactionID != "" {
+		if referenceID, ok := findPaymentByTransaction(transactionID); ok {
+			candidates = append(candidates, referenceID)
+		}
+	}
+	if sale, ok := getSaleSummary(form.Get("original_sale_id")); ok {
+		candidates = append(candidates, sale.ReferenceIDs...)
+	}
+
+	var first paymentRecord
+	for _, referenceID := range candidates {
+		record, ok := getPaymentRecord(referenceID)
+		if !ok {
+			continue
+		}
+		if cmp, err := amount.Cmp(refundable(record)); err == nil && cmp <= 0 {
+			return record, true
+		}
+		if first.ReferenceID == "" {
+			first = record
+		}
+	}
+	return first, first.ReferenceID != ""
+}
+
+// reserveRefund adds the amount to what is refunded of the payment with the
+// given reference ID, unless that would refund more than was paid.
+func reserveRefund(referenceID string, amount money.Money) error {
+	var err error
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		left := refundable(*record)
+		if cmp, cmpErr := amount.Cmp(left); cmpErr != nil {
+			err = cmpErr
+		} else if cmp > 0 {
+			err = fmt.Errorf("refund of %s is more than the %s left to refund", amount, left)
+		} else {
+			record.Refunded, err = record.Refunded.Add(amount)
+		}
+	})
+	return err
+}
+
+// releaseRefund takes back an amount reserved by reserveRefund for a refund
+// that did not go through.
+func releaseRefund(referenceID string, amount money.Money) {
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		if refunded, err := record.Refunded.Sub(amount); err == nil {
+			record.Refunded = refunded
+		}
+	})
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+func TestRefundBookkeeping(t *testing.T) {
+	resetState()
+	addRecord("payment", 1000, func(record *paymentRecord) {
+		record.Status = statusCompleted
+	})
+
+	steps := []struct {
+		name     string
+		reserve  int64 // Released instead if negative
+		wantErr  bool
+		wantLeft int64
+	}{
+		{"first refund", 600, false, 400},
+		{"more than is left", 500, true, 400},
+		{"refund failed", -600, false, 1000},
+		{"whole payment", 1000, false, 0},
+		{"nothing left", 1, true, 0},
+	}
+	for _, step := range steps {
+		var err error
+		if step.reserve < 0 {
+			releaseRefund("payment", money.New(-step.reserve, "NZD"))
+		} else {
+			err = reserveRefund("payment", money.New(step.reserve, "NZD"))
+		}
+		if (err != nil) != step.wantErr {
+			t.Errorf("%s: error = %v, want error %t", step.name, err, step.wantErr)
+		}
+		record, _ := getPaymentRecord("payment")
+		if left := refundable(record); left != money.New(step.wantLeft, "NZD") {
+			t.Errorf("%s: refundable = %v, want %d", step.name, left, step.wantLeft)
+		}
+	}
+}
+
+func TestOriginalPayment(t *testing.T) {
+	resetState()
+	for _, referenceID := range []string{"first", "second"} {
+		newPaymentRecord(referenceID, money.New(500, "NZD"))
+		if err := addSalePayment("sale", money.New(1000, "NZD"), referenceID, money.New(500, "NZD")); err != nil {
+			t.Fatalf("addSalePayment(%s): %s", referenceID, err)
+		}
+		id := referenceID
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.SaleID = "sale"
+			record.Status = statusCompleted
+			record.TransactionIDs = []string{"t-" + id}
+		})
+	}
+	reserveRefund("first", money.New(400, "NZD"))
+
+	tests := []struct {
+		name   string
+		form   url.Values
+		amount int64
+		want   string
+	}{
+		{"by reference", url.Values{"reference_id": {"first"}}, 100, "first"},
+		{"by transaction", url.Values{"transaction_id": {"t-second"}}, 100, "second"},
+		{"sale, first covers it", url.Values{"original_sale_id": {"sale"}}, 100, "first"},
+		{"sale, first refunded", url.Values{"original_sale_id": {"sale"}}, 300, "second"},
+		{"sale, none covers it", url.Values{"original_sale_id": {"sale"}}, 900, "first"},
+		{"unknown", url.Values{"reference_id": {"other"}}, 100, ""},
+	}
+	for _, test := range tests {
+		record, ok := originalPayment(test.form, money.New(test.amount, "NZD"))
+		if record.ReferenceID != test.want || ok != (test.want != "") {
+			t.Errorf("%s: originalPayment = %q, %t, want %q", test.name, record.ReferenceID, ok, test.want)
+		}
+	}
+}
+
+func TestLateRefundCallback(t *testing.T) {
+	manager, stop := newTestManager(&fakePoynt{})
+	defer stop()
+	addRecord("payment", 1000, func(record *paymentRecord) {
+		record.Status = statusCompleted
+	})
+	reserveRefund("payment", money.New(1000, "NZD"))
+	addRecord("refund", 1000, func(record *paymentRecord) {
+		// The wait for the terminal ran out.
+		record.Status = statusUnknown
+		record.Refund = true
+		record.RefundOf = "payment"
+	})
+
+	body := `{"referenceId": "refund", "status": "COMPLETED", "transactions": [{"id": "t2"}]}`
+	req := httptest.NewRequest("POST", "/callback", strings.NewReader(body))
+	manager.Callback(httptest.NewRecorder(), req)
+
+	refund, _ := getPaymentRecord("refund")
+	if refund.Status != statusRefunded {
+		t.Errorf("refund status = %s, want %s", refund.Status, statusRefunded)
+	}
+	if paid := paidAmount(refund); !paid.IsZero() {
+		t.Errorf("refund counted as paying %v", paid)
+	}
+	payment, _ := getPaymentRecord("payment")
+	if left := refundable(payment); !left.IsZero() {
+		t.Errorf("refundable after refund = %v, want 0", left)
+	}
+
+	req = httptest.NewRequest("GET", "/status?reference_id=refund", nil)
+	w := httptest.NewRecorder()
+	manager.PaymentStatus(w, req)
+	if w.Code != http.StatusOK || !strings.Contains(w.Body.String(), statusRefunded) {
+		t.Errorf("status of the refund = %d %s, want it REFUNDED", w.Code, w.Body)
+	}
+}
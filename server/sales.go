@@ -0,0 +1,288 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// Sale statuses.
+const (
+	saleOpen      = "OPEN"
+	salePaid      = "PAID"
+	saleAbandoned = "ABANDONED"
+)
+
+// saleRecord is a Vend sale paid with one or more card payments (split tender).
+// Each payment is its own cloud message and callback, and has its own payment
+// record.
+type saleRecord struct {
+	ID           string
+	Total        money.Money
+	ReferenceIDs []string
+	Status       string
+	CreatedAt    time.Time
+	UpdatedAt    time.Time
+}
+
+// saleSummary is the state of a sale reported to the POS. Vend is only told to
+// ACCEPT once the status is PAID.
+type saleSummary struct {
+	ID           string      `json:"id"`
+	Status       string      `json:"status"`
+	Total        money.Money `json:"total"`
+	Paid         money.Money `json:"paid"`
+	Remaining    money.Money `json:"remaining"`
+	ReferenceIDs []string    `json:"referenceIds"`
+}
+
+var (
+	// Sales by Vend sale ID.
+	sales      = map[string]*saleRecord{}
+	salesMutex = sync.Mutex{}
+	// reverseMutex makes abandoned sales back out their payments one at a
+	// time, so a payment is never reversed twice.
+	reverseMutex = sync.Mutex{}
+)
+
+// paidAmount is how much of the sale a payment covers, zero unless the
+// customer has paid. A partially approved payment only covers what was
+// approved, and a card surcharge covers nothing.
+func paidAmount(record paymentRecord) money.Money {
+	paid := money.New(0, record.Amount.Currency)
+	switch record.Status {
+	case statusCompleted:
+		paid = record.Amount
+		if record.Approved.IsPositive() {
+			paid, _ = record.Approved.Sub(record.Surcharge)
+		}
+	case statusPartial:
+		paid, _ = record.Approved.Sub(record.Surcharge)
+	}
+	if paid.Amount < 0 {
+		return money.New(0, record.Amount.Currency)
+	}
+	return paid
+}
+
+// inFlight reports whether a payment may still be paid.
+func inFlight(record paymentRecord) bool {
+	switch record.Status {
+	case statusPending, statusAuthorized, statusUnknown:
+		return true
+	}
+	return false
+}
+
+// summarize works out the sale's balance from its payments. The salesMutex
+// must be held.
+func (sale *saleRecord) summarize() saleSummary {
+	paid := money.New(0, sale.Total.Currency)
+	for _, referenceID := range sale.ReferenceIDs {
+		if record, ok := getPaymentRecord(referenceID); ok {
+			paid, _ = paid.Add(paidAmount(record))
+		}
+	}
+	remaining, _ := sale.Total.Sub(paid)
+	if remaining.Amount < 0 {
+		remaining = money.New(0, sale.Total.Currency)
+	}
+	if sale.Status == saleOpen && remaining.IsZero() {
+		sale.Status = salePaid
+		sale.UpdatedAt = time.Now()
+	}
+
+	return saleSummary{
+		ID:           sale.ID,
+		Status:       sale.Status,
+		Total:        sale.Total,
+		Paid:         paid,
+		Remaining:    remaining,
+		ReferenceIDs: append([]string(nil), sale.ReferenceIDs...),
+	}
+}
+
+// addSalePayment adds a payment to the sale, opening the sale if it is new. The
+// payment may not be more than what is left once payments still in progress
+// are taken into account. Sales untouched for as long as payment records are
+// kept are forgotten with them.
+func addSalePayment(saleID string, total money.Money, referenceID string, amount money.Money) error {
+	salesMutex.Lock()
+	defer salesMutex.Unlock()
+	before := time.Now().Add(-recordRetention)
+	for id, sale := range sales {
+		if sale.UpdatedAt.Before(before) {
+			delete(sales, id)
+		}
+	}
+	sale, ok := sales[saleID]
+	if !ok {
+		sale = &saleRecord{
+			ID:        saleID,
+			Total:     total,
+			Status:    saleOpen,
+			CreatedAt: time.Now(),
+		}
+		sales[saleID] = sale
+	} else if sale.Total != total {
+		return fmt.Errorf("sale %s total %s does not match %s", saleID, total, sale.Total)
+	}
+
+	summary := sale.summarize()
+	if summary.Status != saleOpen {
+		return fmt.Errorf("sale %s is %s", saleID, summary.Status)
+	}
+	outstanding := summary.Remaining
+	for _, id := range sale.ReferenceIDs {
+		if record, ok := getPaymentRecord(id); ok && inFlight(record) {
+			outstanding, _ = outstanding.Sub(record.Amount)
+		}
+	}
+	if cmp, err := amount.Cmp(outstanding); err != nil {
+		return err
+	} else if cmp > 0 {
+		return fmt.Errorf("payment of %s is more than the %s left to pay", amount, outstanding)
+	}
+
+	sale.ReferenceIDs = append(sale.ReferenceIDs, referenceID)
+	sale.UpdatedAt = time.Now()
+	return nil
+}
+
+// getSaleSummary returns the balance of the sale with the given ID.
+func getSaleSummary(saleID string) (saleSummary, bool) {
+	salesMutex.Lock()
+	defer salesMutex.Unlock()
+	sale, ok := sales[saleID]
+	if !ok {
+		return saleSummary{}, false
+	}
+	return sale.summarize(), true
+}
+
+// abandonSale stops the sale taking payments and backs out the payments
+// already made, voiding them if they have not settled or refunding them
+// otherwise.
+func (manager *Manager) abandonSale(saleID string) (saleSummary, error) {
+	salesMutex.Lock()
+	sale, ok := sales[saleID]
+	if !ok {
+		salesMutex.Unlock()
+		return saleSummary{}, fmt.Errorf("unknown sale %s", saleID)
+	}
+	if sale.Status == salePaid {
+		salesMutex.Unlock()
+		return saleSummary{}, fmt.Errorf("sale %s is already paid", saleID)
+	}
+	sale.Status = saleAbandoned
+	sale.UpdatedAt = time.Now()
+	referenceIDs := append([]string(nil), sale.ReferenceIDs...)
+	salesMutex.Unlock()
+
+	reverseMutex.Lock()
+	defer reverseMutex.Unlock()
+	var failed []string
+	for _, referenceID := range referenceIDs {
+		record, ok := getPaymentRecord(referenceID)
+		if !ok || !paidAmount(record).IsPositive() && record.Status != statusAuthorized {
+			continue
+		}
+		if len(record.TransactionIDs) == 0 {
+			// Nothing to reverse until the transaction is known, from a
+			// webhook or by checking the payment, so the card stays charged.
+			log.Println("No transaction to reverse for abandoned sale payment:", referenceID)
+			failed = append(failed, referenceID)
+			continue
+		}
+		status := statusVoided
+		for _, transactionID := range record.TransactionIDs {
+			reversed, err := transactions.Reverse(context.Background(), manager.Client, transactionID)
+			if err != nil {
+				log.Println("Error reversing abandoned sale payment:", err)
+				failed = append(failed, referenceID)
+				status = record.Status
+				break
+			}
+			if reversed.Action == "REFUND" || reversed.Status == transactions.StatusRefunded {
+				status = statusRefunded
+			}
+		}
+		updatePaymentRecord(referenceID, func(record *paymentRecord) {
+			record.Status = status
+		})
+	}
+
+	summary, _ := getSaleSummary(saleID)
+	if len(failed) > 0 {
+		return summary, fmt.Errorf("error reversing payments %v", failed)
+	}
+	return summary, nil
+}
+
+// reverseIfAbandoned backs out a payment that was authorized or paid after its
+// sale was abandoned, e.g. by a callback or webhook arriving after the wait for
+// it timed out. Called wherever a payment record is settled.
+func (manager *Manager) reverseIfAbandoned(referenceID string) {
+	record, ok := getPaymentRecord(referenceID)
+	if !ok || record.SaleID == "" {
+		return
+	}
+	if !paidAmount(record).IsPositive() && record.Status != statusAuthorized {
+		return
+	}
+	if summary, ok := getSaleSummary(record.SaleID); !ok || summary.Status != saleAbandoned {
+		return
+	}
+
+	log.Println("Payment went through for abandoned sale, reversing:", referenceID)
+	if _, err := manager.abandonSale(record.SaleID); err != nil {
+		log.Println("Error reversing abandoned sale payment:", err)
+	}
+}
+
+// saleAfterPayment returns the sale's balance once one of its payments has
+// finished.
+func (manager *Manager) saleAfterPayment(saleID string) *saleSummary {
+	summary, ok := getSaleSummary(saleID)
+	if !ok {
+		return nil
+	}
+	return &summary
+}
+
+// Sales returns the balance of a split tender sale given by "sale_id".
+func (manager *Manager) Sales(w http.ResponseWriter, r *http.Request) {
+	summary, ok := getSaleSummary(r.URL.Query().Get("sale_id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown sale"})
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// AbandonSale voids or refunds the payments already made towards a sale given
+// by "sale_id" that will not be completed, e.g. when the cashier cancels it
+// part way through a split tender.
+func (manager *Manager) AbandonSale(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	r.ParseForm()
+	summary, err := manager.abandonSale(r.Form.Get("sale_id"))
+	if err != nil && summary.ID == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]interface{}{"sale": summary, "error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
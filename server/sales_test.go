@@ -0,0 +1,147 @@
+package server
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+func TestPaidAmount(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string
+		approved  int64
+		surcharge int64
+		want      int64
+	}{
+		{"completed", statusCompleted, 0, 0, 1000},
+		{"completed with surcharge", statusCompleted, 1030, 30, 1000},
+		{"partially approved", statusPartial, 600, 0, 600},
+		{"partially approved surcharge only", statusPartial, 20, 30, 0},
+		{"pending", statusPending, 0, 0, 0},
+		{"authorized", statusAuthorized, 1000, 0, 0},
+		{"unknown", statusUnknown, 0, 0, 0},
+		{"failed", statusFailed, 0, 0, 0},
+		{"voided", statusVoided, 1000, 0, 0},
+		{"refunded", statusRefunded, 1000, 0, 0},
+	}
+
+	for _, test := range tests {
+		record := paymentRecord{
+			Amount:    money.New(1000, "NZD"),
+			Approved:  money.New(test.approved, "NZD"),
+			Surcharge: money.New(test.surcharge, "NZD"),
+			Status:    test.status,
+		}
+		if got := paidAmount(record); got != money.New(test.want, "NZD") {
+			t.Errorf("%s: paidAmount = %v, want %d", test.name, got, test.want)
+		}
+	}
+}
+
+func TestAddSalePayment(t *testing.T) {
+	resetState()
+	total := money.New(1000, "NZD")
+	complete := func(record *paymentRecord) { record.Status = statusCompleted }
+
+	steps := []struct {
+		name        string
+		referenceID string
+		total       money.Money
+		amount      int64
+		wantErr     bool
+		settle      func(*paymentRecord)
+		wantStatus  string
+	}{
+		{"first card", "a", total, 600, false, nil, saleOpen},
+		// 600 is still in flight, so only 400 may be sent.
+		{"more than outstanding", "b", total, 500, true, nil, saleOpen},
+		{"different total", "b", money.New(2000, "NZD"), 400, true, nil, saleOpen},
+		{"rest", "c", total, 400, false, nil, saleOpen},
+	}
+	for _, step := range steps {
+		newPaymentRecord(step.referenceID, money.New(step.amount, "NZD"))
+		err := addSalePayment("sale", step.total, step.referenceID, money.New(step.amount, "NZD"))
+		if (err != nil) != step.wantErr {
+			t.Errorf("%s: error = %v, want error %t", step.name, err, step.wantErr)
+		}
+		if summary, _ := getSaleSummary("sale"); summary.Status != step.wantStatus {
+			t.Errorf("%s: sale status = %s, want %s", step.name, summary.Status, step.wantStatus)
+		}
+	}
+
+	updatePaymentRecord("a", complete)
+	summary, _ := getSaleSummary("sale")
+	if summary.Status != saleOpen || summary.Remaining != money.New(400, "NZD") {
+		t.Errorf("after first card: %s with %v remaining, want OPEN with 4.00", summary.Status, summary.Remaining)
+	}
+	updatePaymentRecord("c", complete)
+	summary, _ = getSaleSummary("sale")
+	if summary.Status != salePaid || !summary.Remaining.IsZero() {
+		t.Errorf("after both cards: %s with %v remaining, want PAID with 0.00", summary.Status, summary.Remaining)
+	}
+
+	newPaymentRecord("d", money.New(100, "NZD"))
+	if err := addSalePayment("sale", total, "d", money.New(100, "NZD")); err == nil {
+		t.Error("payment to a paid sale: want an error")
+	}
+}
+
+func TestAbandonSale(t *testing.T) {
+	poynt := &fakePoynt{byID: map[string]transactions.Transaction{
+		"t1": {ID: "t1", Status: transactions.StatusAuthorized},
+	}}
+	manager, stop := newTestManager(poynt)
+	defer stop()
+
+	payments := []struct {
+		referenceID    string
+		status         string
+		transactionIDs []string
+	}{
+		{"authorized", statusAuthorized, []string{"t1"}},
+		// The callback carried no transaction and no webhook came yet.
+		{"no-transaction", statusCompleted, nil},
+		{"failed", statusFailed, nil},
+	}
+	for _, payment := range payments {
+		newPaymentRecord(payment.referenceID, money.New(300, "NZD"))
+		if err := addSalePayment("sale", money.New(1000, "NZD"), payment.referenceID, money.New(300, "NZD")); err != nil {
+			t.Fatalf("addSalePayment(%s): %s", payment.referenceID, err)
+		}
+		status, transactionIDs := payment.status, payment.transactionIDs
+		updatePaymentRecord(payment.referenceID, func(record *paymentRecord) {
+			record.SaleID = "sale"
+			record.Status = status
+			record.TransactionIDs = transactionIDs
+		})
+	}
+
+	summary, err := manager.abandonSale("sale")
+	if err == nil || !strings.Contains(err.Error(), "no-transaction") {
+		t.Errorf("abandonSale error = %v, want one naming the payment without a transaction", err)
+	}
+	if summary.Status != saleAbandoned {
+		t.Errorf("sale status = %s, want %s", summary.Status, saleAbandoned)
+	}
+	if len(poynt.voided) != 1 || poynt.voided[0] != "t1" {
+		t.Errorf("voided %v, want [t1]", poynt.voided)
+	}
+
+	want := map[string]string{
+		"authorized":     statusVoided,
+		"no-transaction": statusCompleted, // Still charged, so not reported as reversed.
+		"failed":         statusFailed,
+	}
+	for referenceID, status := range want {
+		if record, _ := getPaymentRecord(referenceID); record.Status != status {
+			t.Errorf("%s status = %s, want %s", referenceID, record.Status, status)
+		}
+	}
+
+	if _, err := manager.abandonSale("unknown"); err == nil {
+		t.Error("abandonSale of an unknown sale: want an error")
+	}
+}
@@ -1,17 +1,20 @@
 package server
 
 import (
+	"context"
 	"fmt"
 	"log"
 	"net/http"
 
 	"github.com/jtrotsky/go-poynt/auth"
 	"github.com/jtrotsky/go-poynt/poyntcloud/config"
+	"github.com/jtrotsky/go-poynt/poyntcloud/devices"
 )
 
 // Run starts our webserver.
 func Run() {
 	// TODO: Auth should only happen when prompted.
+	loadTemplates()
 
 	// Get config first as auth relies on it.
 	config, err := config.GetConfig()
@@ -24,9 +27,47 @@ func Run() {
 	}
 	manager := NewManager(auth, config)
 
-	http.HandleFunc("/", manager.Gateway)          // Has transaction status info.
-	http.HandleFunc("/callback", manager.Callback) // To receive payment responses.
-	http.HandleFunc("/pay", manager.Pay)           // To send payments.
+	// Resolve the terminal's friendly name to its device ID.
+	if config.DeviceName != "" {
+		deviceID, err := devices.ResolveID(context.Background(), manager.Client, config.StoreID, config.DeviceName)
+		if err != nil {
+			fmt.Println("Error resolving device name:", err)
+		} else {
+			config.DeviceID = deviceID
+		}
+	}
+
+	// Payments are refused until the store's currency is known. If it can't be
+	// looked up now it is tried again on the next payment.
+	if _, err = manager.storeCurrency(); err != nil {
+		fmt.Println(err)
+	}
+
+	http.HandleFunc("/", manager.Gateway)                  // Has transaction status info.
+	http.HandleFunc("/callback", manager.Callback)         // To receive payment responses.
+	http.HandleFunc("/pay", manager.Pay)                   // To send payments.
+	http.HandleFunc("/refund", manager.Refund)             // To send card-present refunds.
+	http.HandleFunc("/status", manager.PaymentStatus)      // To check on a payment later.
+	http.HandleFunc("/webhooks", manager.Webhook)          // To receive POYNT webhook events.
+	http.HandleFunc("/sales", manager.Sales)               // Balance of a sale split across cards.
+	http.HandleFunc("/sales/abandon", manager.AbandonSale) // Void or refund a sale's partial payments.
+
+	http.HandleFunc("/api/payments", manager.Payments)     // Create a payment without waiting.
+	http.HandleFunc("/api/payments/", manager.PaymentByID) // A payment's state, with optional long-poll.
+
+	http.HandleFunc("/tabs", manager.Tabs)                   // List hospitality tabs.
+	http.HandleFunc("/tabs/open", manager.OpenTab)           // Pre-authorize a card to open a tab.
+	http.HandleFunc("/tabs/increment", manager.IncrementTab) // Raise a tab's authorization.
+	http.HandleFunc("/tabs/close", manager.CloseTab)         // Capture a tab with its tip.
+	http.HandleFunc("/tabs/close-all", manager.CloseAllTabs) // Capture every open tab at end of day.
+
+	http.HandleFunc("/display", manager.Display)   // To show a custom screen on the terminal.
+	http.HandleFunc("/cart", manager.Cart)         // To show the running sale total.
+	http.HandleFunc("/prompt", manager.Prompt)     // To ask the customer for input.
+	http.HandleFunc("/receipts", manager.Receipts) // To print or send a receipt.
+
+	http.HandleFunc("/admin/devices", manager.Devices)     // Terminal inventory and status.
+	http.HandleFunc("/admin/broadcast", manager.Broadcast) // Message every terminal in the store.
 
 	http.Handle(
 		"/server/assets/",
@@ -0,0 +1,432 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud"
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// Tab statuses.
+const (
+	tabOpen   = "OPEN"
+	tabClosed = "CLOSED"
+	tabVoided = "VOIDED"
+)
+
+// Stages of a tab.
+const (
+	stagePreAuth   = "PRE_AUTH"
+	stageIncrement = "INCREMENT"
+	stageCapture   = "CAPTURE"
+	stageVoid      = "VOID"
+)
+
+// tabRecord is a hospitality tab: a card pre-authorized when the tab is opened,
+// topped up with incremental authorizations as it grows and captured with the
+// tip when it is closed.
+type tabRecord struct {
+	ID            string      `json:"id"` // Reference ID of the pre-auth payment
+	Name          string      `json:"name,omitempty"`
+	TransactionID string      `json:"transactionId"`
+	Status        string      `json:"status"`
+	Authorized    money.Money `json:"authorized"`
+	Captured      money.Money `json:"captured"`
+	Tip           money.Money `json:"tip"`
+	Stages        []tabStage  `json:"stages"`
+	OpenedAt      time.Time   `json:"openedAt"`
+	ClosedAt      *time.Time  `json:"closedAt,omitempty"`
+
+	// busy is set while the tab is being incremented or closed, so only one
+	// change is sent to POYNT at a time.
+	busy bool
+}
+
+// tabStage is one step in the life of a tab.
+type tabStage struct {
+	Stage         string      `json:"stage"`
+	Amount        money.Money `json:"amount"`
+	TransactionID string      `json:"transactionId,omitempty"`
+	At            time.Time   `json:"at"`
+}
+
+var (
+	// Tabs by ID.
+	tabs      = map[string]*tabRecord{}
+	tabsMutex = sync.Mutex{}
+)
+
+// addStage records a stage of the tab. The tabsMutex must be held.
+func (tab *tabRecord) addStage(stage string, amount money.Money, transactionID string) {
+	tab.Stages = append(tab.Stages, tabStage{
+		Stage:         stage,
+		Amount:        amount,
+		TransactionID: transactionID,
+		At:            time.Now(),
+	})
+}
+
+// getTab returns a copy of the tab with the given ID.
+func getTab(id string) (tabRecord, bool) {
+	tabsMutex.Lock()
+	defer tabsMutex.Unlock()
+	tab, ok := tabs[id]
+	if !ok {
+		return tabRecord{}, false
+	}
+	copied := *tab
+	copied.Stages = append([]tabStage(nil), tab.Stages...)
+	return copied, true
+}
+
+// errUnknownTab is returned for a tab ID that was never opened.
+var errUnknownTab = errors.New("unknown tab")
+
+// tabStateError is returned for a tab that can't be changed now, because it is
+// closed or another change is being sent.
+type tabStateError string
+
+func (err tabStateError) Error() string {
+	return string(err)
+}
+
+// tabErrorStatus is the HTTP status to answer a failed tab change with.
+func tabErrorStatus(err error) int {
+	if err == errUnknownTab {
+		return http.StatusNotFound
+	}
+	if _, ok := err.(tabStateError); ok {
+		return http.StatusConflict
+	}
+	return http.StatusBadGateway
+}
+
+// claimTab returns a copy of the open tab with the given ID and marks it busy
+// until releaseTab is called. A tab that is closed or busy can't be claimed.
+func claimTab(id string) (tabRecord, error) {
+	tabsMutex.Lock()
+	defer tabsMutex.Unlock()
+	tab, ok := tabs[id]
+	if !ok {
+		return tabRecord{}, errUnknownTab
+	}
+	if tab.Status != tabOpen {
+		return *tab, tabStateError(fmt.Sprintf("tab %s is %s", id, tab.Status))
+	}
+	if tab.busy {
+		return *tab, tabStateError(fmt.Sprintf("tab %s is being changed, try again", id))
+	}
+	tab.busy = true
+	copied := *tab
+	copied.Stages = append([]tabStage(nil), tab.Stages...)
+	return copied, nil
+}
+
+// releaseTab applies update, if any, to the tab claimed by claimTab and lets it
+// be changed again. It returns a copy of the updated tab.
+func releaseTab(id string, update func(*tabRecord)) tabRecord {
+	tabsMutex.Lock()
+	defer tabsMutex.Unlock()
+	tab := tabs[id]
+	if update != nil {
+		update(tab)
+	}
+	tab.busy = false
+	copied := *tab
+	copied.Stages = append([]tabStage(nil), tab.Stages...)
+	return copied
+}
+
+// listTabs returns the tabs with the given status, oldest first, or every tab
+// if status is empty.
+func listTabs(status string) []tabRecord {
+	tabsMutex.Lock()
+	ids := make([]string, 0, len(tabs))
+	for id, tab := range tabs {
+		if status == "" || tab.Status == status {
+			ids = append(ids, id)
+		}
+	}
+	tabsMutex.Unlock()
+
+	list := make([]tabRecord, 0, len(ids))
+	for _, id := range ids {
+		if tab, ok := getTab(id); ok {
+			list = append(list, tab)
+		}
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].OpenedAt.Before(list[j].OpenedAt) })
+	return list
+}
+
+// openTab pre-authorizes the amount on the customer's card and opens a tab for
+// it once the terminal calls back with the authorization. If the wait ends
+// first, the tab is opened by the callback or webhook that arrives later.
+func (manager *Manager) openTab(ctx context.Context, name string, amount money.Money) (tabRecord, callbackResult) {
+	referenceID := poyntcloud.GenerateReferenceID()
+	newPaymentRecord(referenceID, amount)
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		record.PreAuth = true
+		record.TabName = name
+	})
+	payment := message.NewPreAuth(amount, referenceID)
+	res := manager.sendAndWait(ctx, referenceID, func() (string, error) {
+		return message.SendPayment(ctx, manager.Client, payment, manager.messageTTL())
+	})
+	tab, _ := ensureTab(referenceID)
+	return tab, res
+}
+
+// ensureTab opens the tab for an authorized pre-auth if it is not open yet, and
+// returns it. Called wherever a payment record is authorized, so no hold on a
+// card is left without a tab to close it.
+func ensureTab(referenceID string) (tabRecord, bool) {
+	record, ok := getPaymentRecord(referenceID)
+	if !ok || !record.PreAuth || len(record.TransactionIDs) == 0 {
+		return tabRecord{}, false
+	}
+
+	tabsMutex.Lock()
+	defer tabsMutex.Unlock()
+	if tab, ok := tabs[referenceID]; ok {
+		return *tab, true
+	}
+	if record.Status != statusAuthorized {
+		return tabRecord{}, false
+	}
+
+	// The card may hold less than was asked for, and a capture can't be for
+	// more than is held.
+	authorized := record.Amount
+	if record.Approved.IsPositive() && record.Approved.Amount < record.Amount.Amount {
+		authorized = record.Approved
+	}
+
+	transactionID := record.TransactionIDs[0]
+	tab := &tabRecord{
+		ID:            referenceID,
+		Name:          record.TabName,
+		TransactionID: transactionID,
+		Status:        tabOpen,
+		Authorized:    authorized,
+		Captured:      money.New(0, authorized.Currency),
+		Tip:           money.New(0, authorized.Currency),
+		OpenedAt:      time.Now(),
+	}
+	tab.addStage(stagePreAuth, authorized, transactionID)
+	tabs[tab.ID] = tab
+	return *tab, true
+}
+
+// incrementTab raises the tab's authorization by the given amount.
+func (manager *Manager) incrementTab(id string, amount money.Money) (tabRecord, error) {
+	tab, err := claimTab(id)
+	if err != nil {
+		return tab, err
+	}
+	authorized, err := tab.Authorized.Add(amount)
+	if err != nil {
+		return releaseTab(id, nil), err
+	}
+
+	transaction, err := transactions.Increment(context.Background(), manager.Client, tab.TransactionID, amount)
+	if err != nil {
+		return releaseTab(id, nil), err
+	}
+
+	return releaseTab(id, func(record *tabRecord) {
+		record.Authorized = authorized
+		record.addStage(stageIncrement, amount, transaction.ID)
+	}), nil
+}
+
+// closeTab captures the tab's final amount plus the tip. A final amount of
+// zero voids the authorization instead.
+func (manager *Manager) closeTab(id string, amount, tip money.Money) (tabRecord, error) {
+	tab, err := claimTab(id)
+	if err != nil {
+		return tab, err
+	}
+	if cmp, err := amount.Cmp(tab.Authorized); err != nil {
+		return releaseTab(id, nil), err
+	} else if cmp > 0 {
+		err = fmt.Errorf("final amount %s is more than the %s authorized, increment the tab first",
+			amount, tab.Authorized)
+		return releaseTab(id, nil), err
+	}
+
+	stage, status := stageCapture, tabClosed
+	var transaction *transactions.Transaction
+	if amount.IsZero() && tip.IsZero() {
+		stage, status = stageVoid, tabVoided
+		transaction, err = transactions.Void(context.Background(), manager.Client, tab.TransactionID)
+	} else {
+		transaction, err = transactions.Capture(context.Background(), manager.Client, tab.TransactionID, amount, tip)
+	}
+	if err != nil {
+		return releaseTab(id, nil), err
+	}
+
+	closed := releaseTab(id, func(record *tabRecord) {
+		now := time.Now()
+		record.Status = status
+		record.Captured = amount
+		record.Tip = tip
+		record.ClosedAt = &now
+		record.addStage(stage, amount, transaction.ID)
+	})
+
+	updatePaymentRecord(id, func(payment *paymentRecord) {
+		payment.Status = statusCompleted
+		if status == tabVoided {
+			payment.Status = statusVoided
+		}
+		payment.Approved = amount
+		payment.Tip = tip
+		if !payment.hasTransaction(transaction.ID) {
+			payment.TransactionIDs = append(payment.TransactionIDs, transaction.ID)
+		}
+	})
+	return closed, nil
+}
+
+// parseAmount parses an optional decimal amount in the store currency, zero if
+// it is empty.
+func (manager *Manager) parseAmount(amount string) (money.Money, error) {
+	currency, err := manager.storeCurrency()
+	if err != nil {
+		return money.Money{}, err
+	}
+	if amount == "" {
+		return money.New(0, currency), nil
+	}
+	parsed, err := money.Parse(amount, currency)
+	if err != nil {
+		return money.Money{}, err
+	}
+	if parsed.Amount < 0 {
+		return money.Money{}, fmt.Errorf("amount %s must not be negative", amount)
+	}
+	return parsed, nil
+}
+
+// Tabs lists hospitality tabs, optionally only those with the given "status",
+// e.g. OPEN.
+func (manager *Manager) Tabs(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, listTabs(r.URL.Query().Get("status")))
+}
+
+// OpenTab pre-authorizes an "amount" on the customer's card and opens a tab,
+// optionally with a "name" such as the table number.
+func (manager *Manager) OpenTab(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	r.ParseForm()
+	amount, err := manager.parseAmount(r.Form.Get("amount"))
+	if err != nil || !amount.IsPositive() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+		return
+	}
+
+	tab, res := manager.openTab(r.Context(), r.Form.Get("name"), amount)
+	if tab.ID == "" {
+		writeJSON(w, http.StatusOK, res)
+		return
+	}
+	writeJSON(w, http.StatusOK, tab)
+}
+
+// IncrementTab adds an incremental authorization of "amount" to the open tab
+// given by "tab_id".
+func (manager *Manager) IncrementTab(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	r.ParseForm()
+	amount, err := manager.parseAmount(r.Form.Get("amount"))
+	if err != nil || !amount.IsPositive() {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+		return
+	}
+
+	tab, err := manager.incrementTab(r.Form.Get("tab_id"), amount)
+	if err != nil {
+		writeJSON(w, tabErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, tab)
+}
+
+// CloseTab captures the tab given by "tab_id" for its final "amount" plus an
+// optional "tip". The amount defaults to everything authorized.
+func (manager *Manager) CloseTab(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+	r.ParseForm()
+	tab, ok := getTab(r.Form.Get("tab_id"))
+	if !ok {
+		writeJSON(w, http.StatusNotFound, map[string]string{"error": "unknown tab"})
+		return
+	}
+	amount := tab.Authorized
+	if amountParam := r.Form.Get("amount"); amountParam != "" {
+		var err error
+		if amount, err = manager.parseAmount(amountParam); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid amount"})
+			return
+		}
+	}
+	tip, err := manager.parseAmount(r.Form.Get("tip"))
+	if err != nil {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid tip"})
+		return
+	}
+
+	tab, err = manager.closeTab(tab.ID, amount, tip)
+	if err != nil {
+		writeJSON(w, tabErrorStatus(err), map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, tab)
+}
+
+// CloseAllTabs captures every open tab for what was authorized, without a tip.
+// Run at the end of the day so no authorization is left to lapse.
+func (manager *Manager) CloseAllTabs(w http.ResponseWriter, r *http.Request) {
+	if r.Method != "POST" {
+		writeJSON(w, http.StatusMethodNotAllowed, map[string]string{"error": "POST required"})
+		return
+	}
+
+	closed := []tabRecord{}
+	failed := map[string]string{}
+	for _, tab := range listTabs(tabOpen) {
+		captured, err := manager.closeTab(tab.ID, tab.Authorized, money.New(0, tab.Authorized.Currency))
+		if err != nil {
+			log.Println("Error closing tab:", err)
+			failed[tab.ID] = err.Error()
+			continue
+		}
+		closed = append(closed, captured)
+	}
+
+	status := http.StatusOK
+	if len(failed) > 0 {
+		status = http.StatusBadGateway
+	}
+	writeJSON(w, status, map[string]interface{}{"closed": closed, "failed": failed})
+}
@@ -3,6 +3,7 @@ package server
 import (
 	"fmt"
 	"net/http"
+	"sync"
 	"text/template"
 )
 
@@ -17,11 +18,23 @@ var errorTemplate = `
 </html>
 `
 
-// Index any .html files within a subdirectory of the templates directory.
-var templates = template.Must(template.New("t").ParseGlob("server/templates/*.html"))
+var (
+	templates     *template.Template
+	templatesOnce sync.Once
+)
+
+// loadTemplates indexes any .html files within the templates directory, which
+// is relative to the working directory. Loaded by Run rather than on import,
+// so the package can be loaded from anywhere, e.g. by its tests.
+func loadTemplates() {
+	templatesOnce.Do(func() {
+		templates = template.Must(template.New("t").ParseGlob("server/templates/*.html"))
+	})
+}
 
 // RenderTemplate executes an HTML template from our templates glob.
 func RenderTemplate(w http.ResponseWriter, r *http.Request, name string, data interface{}) {
+	loadTemplates()
 	err := templates.ExecuteTemplate(w, name, data)
 	if err != nil {
 		http.Error(
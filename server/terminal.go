@@ -0,0 +1,82 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud"
+	"github.com/jtrotsky/go-poynt/poyntcloud/actions/message"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+)
+
+// Display shows a custom screen on the terminal. Takes "title", "text" and
+// "image_url" parameters.
+func (manager *Manager) Display(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	screen := message.NewScreen(r.Form.Get("title"), r.Form.Get("text"), r.Form.Get("image_url"))
+	if screen.Title == "" && screen.Text == "" && screen.ImageURL == "" {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "nothing to display"})
+		return
+	}
+
+	messageID, err := message.ShowScreen(r.Context(), manager.Client, screen)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"messageId": messageID})
+}
+
+// Cart shows the running sale total on the terminal as the cashier scans
+// items. Takes a "total" amount, an optional "currency" and optional "items"
+// as a JSON array of {name, quantity, amount}.
+func (manager *Manager) Cart(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	currency := r.Form.Get("currency")
+	if currency == "" {
+		var err error
+		if currency, err = manager.storeCurrency(); err != nil {
+			writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+			return
+		}
+	}
+	total, err := money.Parse(r.Form.Get("total"), currency)
+	if err != nil || total.Amount < 0 {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid total"})
+		return
+	}
+
+	var items []message.CartItem
+	if itemsParam := r.Form.Get("items"); itemsParam != "" {
+		if err := json.Unmarshal([]byte(itemsParam), &items); err != nil {
+			writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid items"})
+			return
+		}
+	}
+
+	cart := message.NewCart(total, items)
+	messageID, err := message.ShowCart(r.Context(), manager.Client, cart)
+	if err != nil {
+		writeJSON(w, http.StatusBadGateway, map[string]string{"error": err.Error()})
+		return
+	}
+	writeJSON(w, http.StatusOK, map[string]string{"messageId": messageID})
+}
+
+// Prompt asks the customer a question on the terminal and waits for their
+// answer. Takes a "type" of EMAIL, PHONE, YES_NO or TEXT and a "question".
+func (manager *Manager) Prompt(w http.ResponseWriter, r *http.Request) {
+	r.ParseForm()
+	promptType := r.Form.Get("type")
+	if !message.ValidPromptType(promptType) {
+		writeJSON(w, http.StatusBadRequest, map[string]string{"error": "invalid prompt type"})
+		return
+	}
+
+	referenceID := poyntcloud.GenerateReferenceID()
+	prompt := message.NewPrompt(promptType, r.Form.Get("question"), referenceID)
+	res := manager.sendAndWait(r.Context(), referenceID, func() (string, error) {
+		return message.SendPrompt(r.Context(), manager.Client, prompt, manager.messageTTL())
+	})
+	writeJSON(w, http.StatusOK, res)
+}
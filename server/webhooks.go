@@ -0,0 +1,192 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/hooks"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// Deliveries older than this are rejected, and those applied within it are
+// acknowledged again without being applied. It covers POYNT retrying a failed
+// delivery for up to a day.
+const webhookTolerance = 25 * time.Hour
+
+var webhookReplays = hooks.NewReplayGuard(webhookTolerance)
+
+// Payment status each transaction event moves a payment to.
+var webhookStatuses = map[string]string{
+	hooks.TransactionAuthorized: statusAuthorized,
+	hooks.TransactionCaptured:   statusCompleted,
+	hooks.TransactionVoided:     statusVoided,
+	hooks.TransactionRefunded:   statusRefunded,
+}
+
+// Webhook receives POYNT webhook deliveries. Unlike Callback it does not rely
+// on the terminal app, so payments are still updated if the app never calls
+// back.
+func (manager *Manager) Webhook(w http.ResponseWriter, r *http.Request) {
+	body, err := ioutil.ReadAll(io.LimitReader(r.Body, 1<<20))
+	if err != nil {
+		http.Error(w, "error reading body", http.StatusBadRequest)
+		return
+	}
+
+	signature := r.Header.Get(hooks.SignatureHeader)
+	if !hooks.VerifySignature(body, signature, manager.Config.WebhookSecret) {
+		log.Println("Rejected webhook with bad signature")
+		http.Error(w, "invalid signature", http.StatusUnauthorized)
+		return
+	}
+
+	event, err := hooks.ParseEvent(body)
+	if err != nil {
+		log.Println("Error parsing webhook:", err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	if err = webhookReplays.Check(event.ID, event.CreatedAt); err == hooks.ErrReplayed {
+		// Already applied, acknowledge so POYNT stops retrying.
+		w.WriteHeader(http.StatusOK)
+		return
+	} else if err == hooks.ErrInProgress {
+		// The same delivery is being applied, have POYNT retry in case it fails.
+		http.Error(w, err.Error(), http.StatusConflict)
+		return
+	} else if err != nil {
+		log.Printf("Rejected webhook %s: %s", event.ID, err)
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	log.Printf("Webhook %s: %s %s", event.ID, event.EventType, event.Resource)
+	if err = manager.applyEvent(r.Context(), event); err != nil {
+		// Not applied, so POYNT's retry must not be taken for a replay.
+		webhookReplays.Done(event.ID, false)
+		log.Printf("Error applying webhook %s: %s", event.ID, err)
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	webhookReplays.Done(event.ID, true)
+	w.WriteHeader(http.StatusOK)
+}
+
+// applyEvent moves the payment a transaction event is about to its new status.
+// Events for transactions that were not sent from here are ignored.
+func (manager *Manager) applyEvent(ctx context.Context, event *hooks.Event) error {
+	status, ok := webhookStatuses[event.EventType]
+	if !ok || !strings.Contains(event.Resource, "/transactions/") {
+		return nil
+	}
+	transactionID := event.ResourceID
+
+	var transaction *transactions.Transaction
+	var err error
+	referenceID, ok := findPaymentByTransaction(transactionID)
+	if !ok {
+		// The terminal has not called back yet, so ask POYNT which payment the
+		// transaction was made for.
+		if transaction, err = transactions.Get(ctx, manager.Client, transactionID); err != nil {
+			return err
+		}
+		referenceID = transaction.ReferenceID()
+		if referenceID == "" {
+			log.Printf("Webhook for transaction %s not sent from here", transactionID)
+			return nil
+		}
+	}
+	record, ok := getPaymentRecord(referenceID)
+	if !ok {
+		return fmt.Errorf("webhook for transaction %s of unknown payment %s", transactionID, referenceID)
+	}
+
+	res := callbackResult{
+		ReferenceID:  referenceID,
+		Status:       status,
+		Transactions: []callbackTransaction{{ID: transactionID}},
+	}
+	// A capture that settles the payment before the terminal calls back has
+	// its approved amount checked the same way, so a partial approval is never
+	// taken for a full one, nor a pre-auth held for more than the card allowed.
+	if (status == statusCompleted || status == statusAuthorized) && inFlight(record) {
+		if transaction == nil {
+			if transaction, err = transactions.Get(ctx, manager.Client, transactionID); err != nil {
+				return err
+			}
+		}
+		res = transactionResult(referenceID, status, transaction)
+		manager.checkApproval(&res)
+	}
+
+	updatePaymentRecord(referenceID, func(record *paymentRecord) {
+		if !record.hasTransaction(transactionID) {
+			record.TransactionIDs = append(record.TransactionIDs, transactionID)
+		}
+		// A late authorization must not undo a capture, but settles a payment
+		// that was given up on.
+		if status == statusAuthorized && record.Status != statusPending && record.Status != statusUnknown {
+			return
+		}
+		// Nor may a capture hide that only part of the amount was approved.
+		if status == statusCompleted && record.Status == statusPartial {
+			return
+		}
+		record.Status = res.Status
+		markRefunded(record)
+		if res.ApprovedAmount > 0 {
+			record.Approved = money.New(res.ApprovedAmount, record.Amount.Currency)
+		}
+	})
+	// A transaction means the terminal got the cloud message.
+	manager.Deliveries.Acknowledge(referenceID)
+	manager.reverseIfAbandoned(referenceID)
+	ensureTab(referenceID)
+
+	if status == statusAuthorized {
+		publishStage(referenceID, eventAuthorized)
+	} else {
+		resolveCallback(res)
+	}
+	return nil
+}
+
+// transactionResult describes a POYNT transaction as the terminal would in its
+// callback, so its approved amount can be checked.
+func transactionResult(referenceID, status string, transaction *transactions.Transaction) callbackResult {
+	amounts := transaction.Amounts
+	result := callbackTransaction{
+		ID:     transaction.ID,
+		Status: transaction.Status,
+		Amounts: &callbackAmounts{
+			TransactionAmount: amounts.TransactionAmount,
+			OrderAmount:       amounts.OrderAmount,
+			TipAmount:         amounts.TipAmount,
+			CashbackAmount:    amounts.CashbackAmount,
+		},
+	}
+	if transaction.ProcessorResponse != nil {
+		result.ProcessorResponse = &processorResponse{
+			Status:         transaction.ProcessorResponse.Status,
+			ApprovedAmount: transaction.ProcessorResponse.ApprovedAmount,
+		}
+	}
+	return callbackResult{
+		ReferenceID:  referenceID,
+		Status:       status,
+		Transactions: []callbackTransaction{result},
+		// The purchase part of what was taken, as the terminal reports it.
+		Amount:         amounts.TransactionAmount - amounts.TipAmount - amounts.CashbackAmount,
+		TipAmount:      amounts.TipAmount,
+		CashbackAmount: amounts.CashbackAmount,
+		Currency:       amounts.Currency,
+	}
+}
@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"testing"
+
+	"github.com/jtrotsky/go-poynt/poyntcloud/hooks"
+	"github.com/jtrotsky/go-poynt/poyntcloud/money"
+	"github.com/jtrotsky/go-poynt/poyntcloud/transactions"
+)
+
+// transactionEvent is a webhook delivery about the transaction.
+func transactionEvent(eventType, transactionID string) *hooks.Event {
+	return &hooks.Event{
+		ID:         "event-" + transactionID,
+		EventType:  eventType,
+		Resource:   "/businesses/business/transactions/" + transactionID,
+		ResourceID: transactionID,
+	}
+}
+
+func TestApplyEvent(t *testing.T) {
+	tests := []struct {
+		name      string
+		status    string // Of the payment before the event
+		refund    bool
+		preAuth   bool
+		eventType string
+		approved  int64 // By the issuer, as POYNT reports it
+		want      string
+		wantTab   money.Money
+	}{
+		{"capture first", statusPending, false, false, hooks.TransactionCaptured, 1000, statusCompleted, money.Money{}},
+		{"partial capture first", statusPending, false, false, hooks.TransactionCaptured, 600, statusPartial, money.Money{}},
+		{"capture after partial", statusPartial, false, false, hooks.TransactionCaptured, 1000, statusPartial, money.Money{}},
+		{"authorization", statusPending, false, false, hooks.TransactionAuthorized, 1000, statusAuthorized, money.Money{}},
+		{"late authorization", statusCompleted, false, false, hooks.TransactionAuthorized, 1000, statusCompleted, money.Money{}},
+		{"authorization after giving up", statusUnknown, false, false, hooks.TransactionAuthorized, 1000, statusAuthorized, money.Money{}},
+		{"void", statusCompleted, false, false, hooks.TransactionVoided, 1000, statusVoided, money.Money{}},
+		{"refund", statusCompleted, false, false, hooks.TransactionRefunded, 1000, statusRefunded, money.Money{}},
+		{"refund captured", statusPending, true, false, hooks.TransactionCaptured, 1000, statusRefunded, money.Money{}},
+		{"pre-auth after giving up", statusUnknown, false, true, hooks.TransactionAuthorized, 600, statusAuthorized, money.New(600, "NZD")},
+	}
+
+	for _, test := range tests {
+		poynt := &fakePoynt{byID: map[string]transactions.Transaction{
+			"t1": {
+				ID:     "t1",
+				Status: transactions.StatusCaptured,
+				Amounts: transactions.Amounts{
+					TransactionAmount: 1000,
+					OrderAmount:       1000,
+					Currency:          "NZD",
+				},
+				ProcessorResponse: &transactions.ProcessorResponse{
+					Status:         "Successful",
+					ApprovedAmount: test.approved,
+				},
+				References: []transactions.Reference{{ID: "payment", Type: "CUSTOM"}},
+			},
+		}}
+		manager, stop := newTestManager(poynt)
+		status, refund, preAuth := test.status, test.refund, test.preAuth
+		addRecord("payment", 1000, func(record *paymentRecord) {
+			record.Status = status
+			record.Refund = refund
+			record.PreAuth = preAuth
+		})
+
+		if err := manager.applyEvent(context.Background(), transactionEvent(test.eventType, "t1")); err != nil {
+			t.Errorf("%s: unexpected error: %s", test.name, err)
+		}
+		record, _ := getPaymentRecord("payment")
+		if record.Status != test.want {
+			t.Errorf("%s: status = %s, want %s", test.name, record.Status, test.want)
+		}
+		if !record.hasTransaction("t1") {
+			t.Errorf("%s: transaction not added to the payment", test.name)
+		}
+		tab, ok := getTab("payment")
+		if ok != test.preAuth || ok && tab.Authorized != test.wantTab {
+			t.Errorf("%s: tab %t for %v, want %t for %v", test.name, ok, tab.Authorized, test.preAuth, test.wantTab)
+		}
+		stop()
+	}
+}
+
+func TestApplyEventIgnored(t *testing.T) {
+	poynt := &fakePoynt{byID: map[string]transactions.Transaction{
+		"other": {ID: "other", Status: transactions.StatusCaptured},
+		"lost":  {ID: "lost", References: []transactions.Reference{{ID: "unknown", Type: "CUSTOM"}}},
+	}}
+	manager, stop := newTestManager(poynt)
+	defer stop()
+
+	tests := []struct {
+		name    string
+		event   *hooks.Event
+		wantErr bool
+	}{
+		{"not a transaction", &hooks.Event{EventType: hooks.TransactionCaptured, Resource: "/businesses/business/orders/1"}, false},
+		{"other event type", transactionEvent(hooks.TransactionUpdated, "other"), false},
+		{"not sent from here", transactionEvent(hooks.TransactionCaptured, "other"), false},
+		// Retried by POYNT, in case the payment turns up.
+		{"unknown payment", transactionEvent(hooks.TransactionCaptured, "lost"), true},
+		{"transaction lookup fails", transactionEvent(hooks.TransactionCaptured, "missing"), true},
+	}
+	for _, test := range tests {
+		if err := manager.applyEvent(context.Background(), test.event); (err != nil) != test.wantErr {
+			t.Errorf("%s: error = %v, want error %t", test.name, err, test.wantErr)
+		}
+	}
+}